@@ -0,0 +1,465 @@
+//Typed attributes attached to the nodes (NodeData) and edges (LinkData) of the network.
+package go_nets
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type AttrGetter interface {
+	GetAttribute(string) interface{} // nil when the attribute is not defined
+	AttributeKeys() []string
+}
+
+type AttrSetter interface {
+	AttrGetter
+	SetAttribute(string, interface{}) error
+}
+
+type AttrKind int
+
+const (
+	StringAttr AttrKind = iota
+	IntAttr
+	FloatAttr
+	BoolAttr
+	TimeAttr
+	MixedAttr // Only in schemas, when the same key holds values of different kinds
+)
+
+func (ak AttrKind) String() string {
+	AKStrings := []string{
+		"string",
+		"int",
+		"float",
+		"bool",
+		"time",
+		"mixed",
+	}
+	return AKStrings[int(ak)]
+}
+
+//normalizeAttribute checks that the value is of a supported kind, and converts it
+//to the canonical go type of that kind (string, int, float64, bool or time.Time).
+func normalizeAttribute(v interface{}) (interface{}, AttrKind, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, StringAttr, true
+	case int:
+		return x, IntAttr, true
+	case int8:
+		return int(x), IntAttr, true
+	case int16:
+		return int(x), IntAttr, true
+	case int32:
+		return int(x), IntAttr, true
+	case int64:
+		return int(x), IntAttr, true
+	case uint8:
+		return int(x), IntAttr, true
+	case uint16:
+		return int(x), IntAttr, true
+	case uint32:
+		return int(x), IntAttr, true
+	case float32:
+		return float64(x), FloatAttr, true
+	case float64:
+		return x, FloatAttr, true
+	case bool:
+		return x, BoolAttr, true
+	case time.Time:
+		return x, TimeAttr, true
+	}
+	return nil, 0, false
+}
+
+func KindOf(v interface{}) (AttrKind, bool) {
+	_, kind, ok := normalizeAttribute(v)
+	return kind, ok
+}
+
+//Conversion from and to text, for persistence
+func formatAttribute(value interface{}) (AttrKind, string, error) {
+	v, kind, ok := normalizeAttribute(value)
+	if !ok {
+		return 0, "", fmt.Errorf("unsupported attribute type %T", value)
+	}
+	switch kind {
+	case IntAttr:
+		return kind, strconv.Itoa(v.(int)), nil
+	case FloatAttr:
+		return kind, strconv.FormatFloat(v.(float64), 'g', -1, 64), nil
+	case BoolAttr:
+		return kind, strconv.FormatBool(v.(bool)), nil
+	case TimeAttr:
+		return kind, formatTime(v.(time.Time)), nil
+	}
+	return kind, v.(string), nil
+}
+
+func parseAttribute(kind AttrKind, s string) (interface{}, error) {
+	switch kind {
+	case StringAttr:
+		return s, nil
+	case IntAttr:
+		return strconv.Atoi(s)
+	case FloatAttr:
+		return strconv.ParseFloat(s, 64)
+	case BoolAttr:
+		return strconv.ParseBool(s)
+	case TimeAttr:
+		return parseTime(s)
+	}
+	return nil, fmt.Errorf("unsupported attribute kind %d", kind)
+}
+
+//Attributes is the generic attribute store.
+type Attributes map[string]interface{}
+
+func NewAttributes(data AttrGetter) Attributes {
+	a := Attributes{}
+	if data == nil {
+		return a
+	}
+	for _, k := range data.AttributeKeys() {
+		if v, _, ok := normalizeAttribute(data.GetAttribute(k)); ok {
+			a[k] = v
+		}
+	}
+	return a
+}
+
+func (a Attributes) GetAttribute(key string) interface{} {
+	return a[key]
+}
+
+func (a Attributes) AttributeKeys() []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (a Attributes) SetAttribute(key string, value interface{}) error {
+	v, _, ok := normalizeAttribute(value)
+	if !ok {
+		return fmt.Errorf("SET_ATTRIBUTE ERROR: unsupported type %T for attribute %q", value, key)
+	}
+	a[key] = v
+	return nil
+}
+
+//Typed getters --
+//The second value is false when the attribute is missing or of a different kind.
+
+func GetString(data AttrGetter, key string) (string, bool) {
+	if data == nil {
+		return "", false
+	}
+	v, ok := data.GetAttribute(key).(string)
+	return v, ok
+}
+
+func GetInt(data AttrGetter, key string) (int, bool) {
+	if data == nil {
+		return 0, false
+	}
+	if v, kind, ok := normalizeAttribute(data.GetAttribute(key)); ok && kind == IntAttr {
+		return v.(int), true
+	}
+	return 0, false
+}
+
+func GetFloat(data AttrGetter, key string) (float64, bool) {
+	if data == nil {
+		return 0, false
+	}
+	switch v, kind, ok := normalizeAttribute(data.GetAttribute(key)); {
+	case ok && kind == FloatAttr:
+		return v.(float64), true
+	case ok && kind == IntAttr:
+		return float64(v.(int)), true
+	}
+	return 0, false
+}
+
+func GetBool(data AttrGetter, key string) (bool, bool) {
+	if data == nil {
+		return false, false
+	}
+	v, ok := data.GetAttribute(key).(bool)
+	return v, ok
+}
+
+func GetTime(data AttrGetter, key string) (time.Time, bool) {
+	if data == nil {
+		return time.Time{}, false
+	}
+	v, ok := data.GetAttribute(key).(time.Time)
+	return v, ok
+}
+
+//Setting an attribute on data that cannot hold it (e.g. an Agent) flattens the data into Attributes first.
+func setAttribute(data AttrGetter, key string, value interface{}) (AttrGetter, error) {
+	switch d := data.(type) {
+	case Attributes:
+		if d == nil {
+			d = Attributes{}
+		}
+		return d, d.SetAttribute(key, value)
+	case AttrSetter:
+		return d, d.SetAttribute(key, value)
+	}
+	a := NewAttributes(data)
+	return a, a.SetAttribute(key, value)
+}
+
+func (n *Node) GetAttribute(key string) interface{} {
+	if n.NodeData == nil {
+		return nil
+	}
+	return n.NodeData.GetAttribute(key)
+}
+
+func (n *Node) SetAttribute(key string, value interface{}) (err error) {
+	n.NodeData, err = setAttribute(n.NodeData, key, value)
+	return err
+}
+
+func (e *Edge) GetAttribute(key string) interface{} {
+	if e.LinkData == nil {
+		return nil
+	}
+	return e.LinkData.GetAttribute(key)
+}
+
+func (e *Edge) SetAttribute(key string, value interface{}) (err error) {
+	e.LinkData, err = setAttribute(e.LinkData, key, value)
+	return err
+}
+
+//Schema discovery --
+
+type Schema map[string]AttrKind
+
+func (s Schema) add(data AttrGetter) {
+	if data == nil {
+		return
+	}
+	for _, k := range data.AttributeKeys() {
+		kind, ok := KindOf(data.GetAttribute(k))
+		if !ok {
+			continue
+		}
+		if known, ok := s[k]; ok && known != kind {
+			kind = MixedAttr
+		}
+		s[k] = kind
+	}
+}
+
+func (n *Network) NodeSchema() Schema {
+	s := Schema{}
+	for _, node := range n.Nodes {
+		s.add(node.NodeData)
+	}
+	return s
+}
+
+func (n *Network) EdgeSchema() Schema {
+	s := Schema{}
+	for _, edge := range n.Edges {
+		s.add(edge.LinkData)
+	}
+	return s
+}
+
+//Filtering on attributes --
+
+func attributeMatches(data AttrGetter, key string, value interface{}) bool {
+	if data == nil {
+		return false
+	}
+	v1, _, ok1 := normalizeAttribute(data.GetAttribute(key))
+	v2, _, ok2 := normalizeAttribute(value)
+	if t1, ok := v1.(time.Time); ok { // Same instant, whatever the location
+		t2, ok := v2.(time.Time)
+		return ok && t1.Equal(t2)
+	}
+	return ok1 && ok2 && v1 == v2
+}
+
+func (n *Network) SearchNodesByAttribute(key string, value interface{}) []*Node {
+	matchingNodes := []*Node{}
+	for _, node := range n.Nodes {
+		if attributeMatches(node.NodeData, key, value) {
+			matchingNodes = append(matchingNodes, node)
+		}
+	}
+	return matchingNodes
+}
+
+func (n *Network) SearchEdgesByAttribute(key string, value interface{}) []*Edge {
+	matchingEdges := []*Edge{}
+	for _, edge := range n.Edges {
+		if attributeMatches(edge.LinkData, key, value) {
+			matchingEdges = append(matchingEdges, edge)
+		}
+	}
+	return matchingEdges
+}
+
+//Persistence --
+//The attributes are saved next to the nodes and edges tables, one row per attribute.
+//The data of a registered type is also saved as JSON in the nodes and edges tables, to be loaded back as is:
+//its attributes are then only saved for the queries on the file.
+
+var (
+	dataTypes     = map[string]reflect.Type{}
+	dataTypeNames = map[reflect.Type]string{}
+)
+
+//RegisterData registers the type of the given NodeData or LinkData (a pointer or a value) under a name,
+//so that the data of this type is saved as JSON with the network and loaded back with the same type.
+func RegisterData(name string, data AttrGetter) {
+	t := reflect.TypeOf(data)
+	dataTypes[name] = t
+	dataTypeNames[t] = name
+}
+
+func isRegisteredData(data AttrGetter) bool {
+	_, ok := dataTypeNames[reflect.TypeOf(data)]
+	return ok
+}
+
+//encodeData returns the name of the type of the data, and its JSON encoding. Both are nil when the type is not registered.
+func encodeData(data AttrGetter) (interface{}, interface{}, error) {
+	name, ok := dataTypeNames[reflect.TypeOf(data)]
+	if !ok {
+		return nil, nil, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return name, string(b), nil
+}
+
+func decodeData(name, data string) (AttrGetter, error) {
+	t, ok := dataTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unregistered data type %q", name)
+	}
+	if t.Kind() == reflect.Ptr {
+		v := reflect.New(t.Elem())
+		err := json.Unmarshal([]byte(data), v.Interface())
+		return v.Interface().(AttrGetter), err
+	}
+	v := reflect.New(t)
+	err := json.Unmarshal([]byte(data), v.Interface())
+	return v.Elem().Interface().(AttrGetter), err
+}
+
+func saveAttributes(db *sql.DB, d Dialect, table string, forEach func(save func(owner string, data AttrGetter))) {
+	sqlStmt := `CREATE TABLE ` + table + ` (owner TEXT NOT NULL, key TEXT NOT NULL, kind INT, value TEXT, PRIMARY KEY(owner, key))`
+	if _, err := db.Exec(sqlStmt); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	loader, err := d.BulkLoad(tx, table, "owner", "key", "kind", "value")
+	if err != nil {
+		log.Fatal(err)
+	}
+	i := 0
+	forEach(func(owner string, data AttrGetter) {
+		if data == nil {
+			return
+		}
+		for _, key := range data.AttributeKeys() {
+			kind, value, err := formatAttribute(data.GetAttribute(key))
+			if err != nil {
+				log.Printf("SAVE_ATTRIBUTES WARNING: skipping attribute %q of %q: %s", key, owner, err)
+				continue
+			}
+			if err = loader.Add(owner, key, kind, value); err != nil {
+				log.Fatal(err)
+			}
+			i++
+		}
+	})
+	fmt.Printf("Comitting %d attributes into table %s...\n", i, table)
+	commitBulk(tx, loader)
+}
+
+func (n *Network) SaveAttributes(fp string) {
+	fmt.Printf("Trying to save the attributes of network %q into file %q\n", n.Name, fp)
+	db, d := n.openDB(fp)
+	defer db.Close()
+	saveAttributes(db, d, "node_attributes", func(save func(string, AttrGetter)) {
+		for _, node := range n.Nodes {
+			save(node.Name, node.NodeData)
+		}
+	})
+	saveAttributes(db, d, "edge_attributes", func(save func(string, AttrGetter)) {
+		for _, edge := range n.Edges {
+			save(edge.Name, edge.LinkData)
+		}
+	})
+}
+
+func loadAttributes(db *sql.DB, table string, set func(owner, key string, value interface{}) bool) {
+	rows, err := db.Query("SELECT owner, key, kind, value FROM " + table)
+	if err != nil { // Files saved before the attributes were persisted
+		log.Printf("LOAD_ATTRIBUTES WARNING: no attributes loaded from table %s: %s", table, err)
+		return
+	}
+	defer rows.Close()
+	var (
+		owner, key, value string
+		kind              AttrKind
+	)
+	for rows.Next() {
+		if err := rows.Scan(&owner, &key, &kind, &value); err != nil {
+			log.Fatal(err)
+		}
+		v, err := parseAttribute(kind, value)
+		if err != nil {
+			log.Printf("LOAD_ATTRIBUTES WARNING: attribute %q of %q: %s", key, owner, err)
+			continue
+		}
+		if !set(owner, key, v) {
+			log.Printf("LOAD_ATTRIBUTES WARNING: attribute %q refers to the missing %q in table %s", key, owner, table)
+		}
+	}
+}
+
+func (n *Network) LoadAttributes(fp string) {
+	fmt.Printf("Trying to load the attributes into network %q from file %q\n", n.Name, fp)
+	db, _ := n.openDB(fp)
+	defer db.Close()
+	loadAttributes(db, "node_attributes", func(owner, key string, value interface{}) bool {
+		node, ok := n.Nodes[owner]
+		if ok && !isRegisteredData(node.NodeData) { // The registered data is loaded as a whole with the node
+			node.SetAttribute(key, value)
+		}
+		return ok
+	})
+	loadAttributes(db, "edge_attributes", func(owner, key string, value interface{}) bool {
+		edge, ok := n.Edges[owner]
+		if ok && !isRegisteredData(edge.LinkData) {
+			edge.SetAttribute(key, value)
+		}
+		return ok
+	})
+}
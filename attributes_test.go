@@ -0,0 +1,107 @@
+package go_nets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestAttributes(t *testing.T) {
+	fmt.Println("### TESTING the attributes")
+	a := Attributes{}
+	if err := a.SetAttribute("count", int32(3)); err != nil {
+		t.Error(err)
+	}
+	if err := a.SetAttribute("ratio", float32(0.5)); err != nil {
+		t.Error(err)
+	}
+	if err := a.SetAttribute("bad", []int{1}); err == nil {
+		t.Error("Setting an unsupported type should fail")
+	}
+	if v, ok := GetInt(a, "count"); !ok || v != 3 {
+		t.Errorf("GetInt: got %v (%t), expected 3", v, ok)
+	}
+	if v, ok := GetFloat(a, "ratio"); !ok || v != 0.5 {
+		t.Errorf("GetFloat: got %v (%t), expected 0.5", v, ok)
+	}
+	if _, ok := GetString(a, "count"); ok {
+		t.Error("GetString should fail on an int attribute")
+	}
+	// Nodes keep the data of their noder, and can be extended
+	network := NewNetwork("TestAttributes", ioutil.Discard, testFolder)
+	f := newTestFiling(137363375543, []string{"EMPLOYMENT DEVELOPMENT DEPARTMENT"}, []string{"john.doe", "jane.doe"})
+	network.AddDispatcher(&f)
+	node := network.Nodes["employment_development_department"]
+	if city, _ := GetString(node.NodeData, "city"); city != "San Francisco" {
+		t.Errorf("Got city %q for the secured party, expected San Francisco", city)
+	}
+	if err := node.SetAttribute("flagged", true); err != nil {
+		t.Error(err)
+	}
+	if flagged, _ := GetBool(node.NodeData, "flagged"); !flagged || node.GetAttribute("city") != "San Francisco" {
+		t.Errorf("Setting an attribute lost the data of the node: %v", node.NodeData)
+	}
+	// Schema discovery
+	nodeSchema, edgeSchema := network.NodeSchema(), network.EdgeSchema()
+	if nodeSchema["flagged"] != BoolAttr || nodeSchema["last_name"] != StringAttr {
+		t.Errorf("Unexpected node schema %v", nodeSchema)
+	}
+	if edgeSchema["file_number"] != IntAttr || edgeSchema["file_date"] != TimeAttr {
+		t.Errorf("Unexpected edge schema %v", edgeSchema)
+	}
+	// Filtering
+	if nodes := network.SearchNodesByAttribute("city", "Sacramento"); len(nodes) != 2 {
+		t.Errorf("Found %d nodes in Sacramento, expected 2", len(nodes))
+	}
+	if edges := network.SearchEdgesByAttribute("file_number", 137363375543); len(edges) != 3 {
+		t.Errorf("Found %d edges for the filing, expected 3", len(edges))
+	}
+	// Persistence
+	network.SaveAs(testFolder + "TestAttributes.sqlite")
+	network2 := NewNetwork("TestAttributes2", ioutil.Discard, testFolder)
+	network2.LoadFrom(testFolder + "TestAttributes.sqlite")
+	if s1, s2 := fmt.Sprint(network.NodeSchema()), fmt.Sprint(network2.NodeSchema()); s1 != s2 {
+		t.Errorf("Node schema changed from %s to %s when loading", s1, s2)
+	}
+	for name, edge := range network.Edges {
+		for _, k := range edge.LinkData.AttributeKeys() {
+			if v1, v2 := edge.GetAttribute(k), network2.Edges[name].GetAttribute(k); v1 != v2 {
+				t.Errorf("Attribute %q of edge %q changed from %v to %v when loading", k, name, v1, v2)
+			}
+		}
+	}
+}
+
+func TestDataPersistence(t *testing.T) {
+	fmt.Println("### TESTING the persistence of the node and edge data")
+	network := NewNetwork("TestDataPersistence", ioutil.Discard, testFolder)
+	f := newTestFiling(137363375543, []string{"EMPLOYMENT DEVELOPMENT DEPARTMENT"}, []string{"john.doe", "jane.doe"})
+	network.AddDispatcher(&f)
+	network.AddNode(&SimpleNoder{Name: "plain", Kind: Emitter})
+	network.SaveAs(testFolder + "TestDataPersistence.sqlite")
+	network2 := NewNetwork("TestDataPersistence2", ioutil.Discard, testFolder)
+	network2.LoadFrom(testFolder + "TestDataPersistence.sqlite")
+	for name, node := range network.Nodes {
+		if _, ok := node.NodeData.(*AgentData); !ok {
+			continue
+		}
+		if !reflect.DeepEqual(node.NodeData, network2.Nodes[name].NodeData) {
+			t.Errorf("Data of node %q changed from %#v to %#v when loading", name, node.NodeData, network2.Nodes[name].NodeData)
+		}
+	}
+	for name, edge := range network.Edges {
+		fd, ok := network2.Edges[name].LinkData.(FilingData)
+		if !ok {
+			t.Errorf("Got data of type %T for edge %q, expected FilingData", network2.Edges[name].LinkData, name)
+			continue
+		}
+		if efd := edge.LinkData.(FilingData); fd.FileNumber != efd.FileNumber || !fd.FileDate.Equal(efd.FileDate) || fd.FilingType != efd.FilingType {
+			t.Errorf("Data of edge %q changed from %v to %v when loading", name, efd, fd)
+		}
+	}
+	// The other data is still loaded from the attributes
+	if network2.Nodes["plain"] == nil {
+		t.Error("Node without registered data not loaded")
+	}
+}
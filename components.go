@@ -0,0 +1,204 @@
+package go_nets
+
+import (
+	"sort"
+)
+
+//Connected components --
+//The components are found with a union-find over the edges: no recursion and no step limit, whatever the depth
+//of the network. They are the groups of nodes linked by edges whatever their direction, i.e. the subnetworks
+//found by DetectSubs from any of their nodes when the network is symmetrical.
+
+//Components of a network. The ids go from 0, the biggest components first (the ties being ordered by the name
+//of their first member), and the members are sorted by name, so that the same network always gives the same answer.
+type Components struct {
+	Of      map[*Node]int // Id of the component of each node
+	Members [][]*Node     // Nodes of each component
+}
+
+func (c *Components) Len() int {
+	return len(c.Members)
+}
+
+func (c *Components) Size(id int) int {
+	return len(c.Members[id])
+}
+
+func (c *Components) Sizes() []int {
+	sizes := make([]int, len(c.Members))
+	for id, members := range c.Members {
+		sizes[id] = len(members)
+	}
+	return sizes
+}
+
+//SubNetwork returns the component as a subnetwork, as returned by DetectSubs.
+func (c *Components) SubNetwork(id int) map[*Node]bool {
+	subNetwork := make(map[*Node]bool, len(c.Members[id]))
+	for _, node := range c.Members[id] {
+		subNetwork[node] = true
+	}
+	return subNetwork
+}
+
+//unionFind is a disjoint-set forest over the indexes of the nodes, with union by size and path halving.
+type unionFind struct {
+	parent, size []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{make([]int, n), make([]int, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+		uf.size[i] = 1
+	}
+	return uf
+}
+
+func (uf *unionFind) find(i int) int {
+	for uf.parent[i] != i {
+		uf.parent[i] = uf.parent[uf.parent[i]]
+		i = uf.parent[i]
+	}
+	return i
+}
+
+func (uf *unionFind) union(i, j int) {
+	ri, rj := uf.find(i), uf.find(j)
+	if ri == rj {
+		return
+	}
+	if uf.size[ri] < uf.size[rj] {
+		ri, rj = rj, ri
+	}
+	uf.parent[rj] = ri
+	uf.size[ri] += uf.size[rj]
+}
+
+//Components returns the connected components of the network.
+func (n *Network) Components() *Components {
+	nodes := n.sortedNodes()
+	index := make(map[*Node]int, len(nodes))
+	for i, node := range nodes {
+		index[node] = i
+	}
+	uf := newUnionFind(len(nodes))
+	for _, edge := range n.Edges {
+		uf.union(index[edge.Src], index[edge.Dst])
+	}
+	return newComponents(nodes, uf.find)
+}
+
+//newComponents gathers the nodes, sorted by name, by the root of their component.
+func newComponents(nodes []*Node, root func(i int) int) *Components {
+	byRoot := map[int][]*Node{}
+	roots := []int{}
+	for i, node := range nodes {
+		r := root(i)
+		if _, ok := byRoot[r]; !ok {
+			roots = append(roots, r)
+		}
+		byRoot[r] = append(byRoot[r], node)
+	}
+	c := &Components{make(map[*Node]int, len(nodes)), make([][]*Node, 0, len(roots))}
+	for _, root := range roots {
+		c.Members = append(c.Members, byRoot[root])
+	}
+	sort.SliceStable(c.Members, func(i, j int) bool { return len(c.Members[i]) > len(c.Members[j]) })
+	for id, members := range c.Members {
+		for _, node := range members {
+			c.Of[node] = id
+		}
+	}
+	return c
+}
+
+//Number of nodes a wanderer expands between two reports to the coordinator of CcrComponents
+const ccrStepSize = 1 << 10
+
+//CcrComponents finds the components with nWorkers wanderers (see SimpleWanderer.WanderStep) exploring the network
+//concurrently, from different seeds. Each wanderer reports the nodes it finds after each step; when it finds a node
+//found by another one, their frontiers meet: one of them breaks and is merged into the other, which goes on
+//with both frontiers. A wanderer is done when its subnetwork is complete, and a new one starts from the next
+//node not found yet. The result is the same as the one of Components.
+func (n *Network) CcrComponents(nWorkers int) *Components {
+	if nWorkers < 1 {
+		nWorkers = 1
+	}
+	nodes := n.sortedNodes()
+	index := make(map[*Node]int, len(nodes))
+	for i, node := range nodes {
+		index[node] = i
+	}
+	// The wanderers are numbered by the index of their seed. owner holds the wanderer that found each node first
+	// (-1 when not found yet), and the wanderers whose frontiers met are merged in the union-find, into its roots.
+	owner := make([]int, len(nodes))
+	for i := range owner {
+		owner[i] = -1
+	}
+	wanderers := newUnionFind(len(nodes))
+	coms := map[int]*WandererCom{}        // Running wanderers
+	broken := map[int][]*SimpleWanderer{} // Wanderers waiting to be merged into their root
+	running, seed := []int{}, 0
+	launch := func() {
+		for ; seed < len(nodes) && len(coms) < nWorkers; seed++ {
+			if owner[seed] != -1 {
+				continue
+			}
+			owner[seed] = seed
+			coms[seed] = NewWandererCom()
+			running = append(running, seed)
+			go NewSimpleWanderer().WanderStep(nodes[seed], ccrStepSize, *coms[seed])
+		}
+	}
+	// A root absorbs the running wanderers merged into it before being done
+	absorbing := func(root int) bool {
+		for w := range coms {
+			if w != root && wanderers.find(w) == root {
+				return true
+			}
+		}
+		return false
+	}
+	launch()
+	for len(running) > 0 {
+		next := []int{}
+		for _, w := range running {
+			com := coms[w]
+			status := <-com.cOrder
+			for node := range <-com.cSubN {
+				if i := index[node]; owner[i] == -1 {
+					owner[i] = w
+				} else {
+					wanderers.union(w, owner[i]) // The frontiers meet
+				}
+			}
+			switch root := wanderers.find(w); {
+			case root != w: // Merged into another wanderer
+				com.cOrder <- Break
+				broken[root] = append(append(broken[root], <-com.cWanderer), broken[w]...)
+				delete(broken, w)
+				delete(coms, w)
+			case len(broken[w]) > 0:
+				for _, sw := range broken[w] {
+					com.cOrder <- Merge
+					com.cWanderer <- sw
+				}
+				delete(broken, w)
+				com.cOrder <- Continue
+				next = append(next, w)
+			case status == Done && !absorbing(w):
+				com.cOrder <- Done
+				delete(coms, w)
+			default:
+				com.cOrder <- Continue
+				next = append(next, w)
+			}
+		}
+		running = next
+		launch()
+	}
+	return newComponents(nodes, func(i int) int {
+		return wanderers.find(owner[i])
+	})
+}
@@ -0,0 +1,103 @@
+package go_nets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestComponents(t *testing.T) {
+	fmt.Println("### TESTING the connected components")
+	network := newTestNetwork("TestComponents", [][3]string{
+		{"a", "b", "ER"}, {"b", "c", "EE"}, {"c", "a", "RR"}, {"c", "d", "ER"},
+		{"e", "f", "ER"}, {"g", "h", "ER"}, {"h", "i", "ER"},
+	})
+	network.AddNode(&SimpleNoder{Name: "alone", Kind: Emitter})
+	c := network.Components()
+	if sizes := fmt.Sprint(c.Sizes()); sizes != "[4 3 2 1]" {
+		t.Errorf("Got the sizes %s, expected [4 3 2 1]", sizes)
+	}
+	if c.Of[network.Nodes["e"]] != 2 || c.Members[1][0].Name != "g" {
+		t.Errorf("Unexpected components %v", c.Members)
+	}
+	// Same answer as DetectSubs
+	for _, node := range network.Nodes {
+		sub, _ := DetectSubs(node, network.Nnodes)
+		if component := c.SubNetwork(c.Of[node]); len(sub) != len(component) {
+			t.Errorf("Got %d nodes from DetectSubs on %q, expected %d", len(sub), node.Name, len(component))
+		} else {
+			for n := range sub {
+				if !component[n] {
+					t.Errorf("Node %q found by DetectSubs on %q is not in its component", n.Name, node.Name)
+				}
+			}
+		}
+	}
+	// The net has no empty component anymore
+	net := NewNet()
+	net.CrunchNetwork(&network)
+	net.Summary(nil)
+	if len(net.SubNetworks) != 4 || len(net.SubNetworks[0]) != 4 || net.NodeMap[network.Nodes["alone"]] != 3 {
+		t.Errorf("Got %d subnetworks, expected the 4 components", len(net.SubNetworks))
+	}
+	// A deep chain, directed
+	chain := NewNetwork("TestChain", ioutil.Discard, testFolder)
+	chain.Symmetrical = false
+	for i := 0; i < 100000; i++ {
+		chain.AddNode(&SimpleNoder{Name: strconv.Itoa(i), Kind: Emitter})
+		if i > 0 {
+			chain.AddEdge(&SimpleEdger{Name: strconv.Itoa(i), Kind: EE, SrcId: strconv.Itoa(i), DstId: strconv.Itoa(i - 1)})
+		}
+	}
+	if c := chain.Components(); c.Len() != 1 || c.Size(0) != 100000 {
+		t.Errorf("Got %d components for the chain, expected 1", c.Len())
+	}
+}
+
+func TestCcrComponents(t *testing.T) {
+	fmt.Println("### TESTING the concurrent connected components")
+	r := rand.New(rand.NewSource(42))
+	for _, symmetrical := range []bool{true, false} {
+		network := NewNetwork("TestCcrComponents", ioutil.Discard, testFolder)
+		network.Symmetrical = symmetrical
+		nNodes := 50000
+		for i := 0; i < nNodes; i++ {
+			network.AddNode(&SimpleNoder{Name: strconv.Itoa(i), Kind: Emitter})
+		}
+		for i := 0; i < 40000; i++ {
+			src, dst := strconv.Itoa(r.Intn(nNodes)), strconv.Itoa(r.Intn(nNodes))
+			network.AddEdge(&SimpleEdger{Name: src + "_" + dst, Kind: EE, SrcId: src, DstId: dst})
+		}
+		expected := network.Components()
+		for _, nWorkers := range []int{1, 4, 16} {
+			t0 := time.Now()
+			c := network.CcrComponents(nWorkers)
+			fmt.Printf("Found %d components (largest: %d nodes) with %d workers in %v\n", c.Len(), c.Size(0), nWorkers, time.Since(t0))
+			if !reflect.DeepEqual(c.Members, expected.Members) {
+				t.Errorf("Got different components with %d workers (symmetrical: %t)", nWorkers, symmetrical)
+			}
+		}
+	}
+	// A deep chain, the seeds of the wanderers being scattered along it
+	chain := NewNetwork("TestCcrChain", ioutil.Discard, testFolder)
+	chain.Symmetrical = false
+	for i := 0; i < 20000; i++ {
+		chain.AddNode(&SimpleNoder{Name: strconv.Itoa(i), Kind: Emitter})
+		if i > 0 {
+			chain.AddEdge(&SimpleEdger{Name: strconv.Itoa(i), Kind: EE, SrcId: strconv.Itoa(i), DstId: strconv.Itoa(i - 1)})
+		}
+	}
+	if c := chain.CcrComponents(8); c.Len() != 1 || c.Size(0) != 20000 {
+		t.Errorf("Got %d components for the chain, expected 1", c.Len())
+	}
+	network := newTestNetwork("TestCcrCrunch", [][3]string{{"a", "b", "ER"}, {"b", "c", "EE"}, {"e", "f", "ER"}})
+	net := NewNet()
+	net.CcrCrunchNetwork(&network, 4)
+	if len(net.SubNetworks) != 2 || len(net.SubNetworks[0]) != 3 {
+		t.Errorf("Got %d subnetworks, expected 2", len(net.SubNetworks))
+	}
+}
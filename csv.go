@@ -0,0 +1,282 @@
+package go_nets
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+)
+
+//CSV and TSV --
+//The edge lists and node tables are read row by row, each row being added to the network right away:
+//the files are never held in memory. The columns are found by their names in the header.
+
+//CSVFormat gives the separator and the names of the columns. The empty names are left out: the kinds are then
+//the default ones (Emitter, ER), the weights the DefaultWeight, and the edges are named "source_target".
+type CSVFormat struct {
+	Comma          rune     // ',' when 0
+	Id             string   // Name of the nodes in the node tables, of the edges in the edge lists
+	Source, Target string   // Ends of the edges
+	Kind           string   // Name (e.g. "Emitter-Receiver" or "ER") or number of the kind
+	Weight         string   // Weight of the edges
+	Attributes     []string // Attributes of the data, imported as strings
+}
+
+var (
+	CSV = CSVFormat{',', "id", "source", "target", "kind", "weight", nil}
+	TSV = CSVFormat{'\t', "id", "source", "target", "kind", "weight", nil}
+)
+
+func (f CSVFormat) reader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	if f.Comma != 0 {
+		cr.Comma = f.Comma
+	}
+	cr.ReuseRecord = true
+	return cr
+}
+
+func (f CSVFormat) writer(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if f.Comma != 0 {
+		cw.Comma = f.Comma
+	}
+	return cw
+}
+
+//csvColumns maps the names of the columns to their index in the header.
+type csvColumns map[string]int
+
+func readHeader(cr *csv.Reader) (csvColumns, error) {
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("CSV ERROR: reading the header: %v", err)
+	}
+	columns := csvColumns{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	return columns, nil
+}
+
+//value returns the value of the column in the record, false when the column is not in the file.
+func (c csvColumns) value(record []string, name string) (string, bool) {
+	i, ok := c[name]
+	if name == "" || !ok {
+		return "", false
+	}
+	return record[i], true
+}
+
+func (c csvColumns) require(names ...string) error {
+	for _, name := range names {
+		if _, ok := c[name]; name == "" || !ok {
+			return fmt.Errorf("CSV ERROR: missing column %q", name)
+		}
+	}
+	return nil
+}
+
+func (c csvColumns) attributes(record []string, names []string) Attributes {
+	var data Attributes
+	for _, name := range names {
+		if v, ok := c.value(record, name); ok && v != "" {
+			if data == nil {
+				data = Attributes{}
+			}
+			data[name] = v
+		}
+	}
+	return data
+}
+
+func parseCSVNodeKind(s string) (NodeKind, bool) {
+	if nk, ok := ParseNodeKind(s); ok {
+		return nk, true
+	}
+	i, err := strconv.Atoi(s)
+	return NodeKind(i), err == nil && NodeKind(i) >= Emitter && NodeKind(i) <= Hub
+}
+
+func parseCSVEdgeKind(s string) (EdgeKind, bool) {
+	if ek, ok := ParseEdgeKind(s); ok {
+		return ek, true
+	}
+	i, err := strconv.Atoi(s)
+	return EdgeKind(i), err == nil && EdgeKind(i) >= ER && EdgeKind(i) <= HR
+}
+
+//endKinds are the kinds of the nodes at the ends of an edge of the given kind.
+func endKinds(ek EdgeKind) (NodeKind, NodeKind) {
+	switch ek {
+	case EE:
+		return Emitter, Emitter
+	case RR:
+		return Receiver, Receiver
+	case EH:
+		return Emitter, Hub
+	case HR:
+		return Hub, Receiver
+	}
+	return Emitter, Receiver
+}
+
+//ReadNodeTable adds the nodes of a node table to the network.
+func (n *Network) ReadNodeTable(r io.Reader, format CSVFormat) error {
+	cr := format.reader(r)
+	columns, err := readHeader(cr)
+	if err != nil {
+		return err
+	}
+	if err = columns.require(format.Id); err != nil {
+		return err
+	}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("CSV ERROR: %v", err)
+		}
+		sn := SimpleNoder{Name: record[columns[format.Id]]}
+		if kind, ok := columns.value(record, format.Kind); ok {
+			if sn.Kind, ok = parseCSVNodeKind(kind); !ok {
+				log.Printf("CSV WARNING: line %d: unknown kind %q of node %q", line, kind, sn.Name)
+			}
+		}
+		sn.Data = columns.attributes(record, format.Attributes)
+		n.AddNode(&sn)
+	}
+}
+
+//ReadEdgeList adds the edges of an edge list to the network. The nodes missing from the network are added,
+//with the kinds of the ends of the edge (e.g. an emitter and a receiver for an ER edge).
+func (n *Network) ReadEdgeList(r io.Reader, format CSVFormat) error {
+	cr := format.reader(r)
+	columns, err := readHeader(cr)
+	if err != nil {
+		return err
+	}
+	if err = columns.require(format.Source, format.Target); err != nil {
+		return err
+	}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("CSV ERROR: %v", err)
+		}
+		se := SimpleEdger{SrcId: record[columns[format.Source]], DstId: record[columns[format.Target]]}
+		se.Name, _ = columns.value(record, format.Id)
+		if se.Name == "" {
+			se.Name = se.SrcId + "_" + se.DstId
+		}
+		if kind, ok := columns.value(record, format.Kind); ok {
+			if se.Kind, ok = parseCSVEdgeKind(kind); !ok {
+				log.Printf("CSV WARNING: line %d: unknown kind %q of edge %q", line, kind, se.Name)
+			}
+		}
+		if weight, ok := columns.value(record, format.Weight); ok && weight != "" {
+			w, err := strconv.ParseFloat(weight, 32)
+			if err != nil {
+				return fmt.Errorf("CSV ERROR: line %d: weight of edge %q: %v", line, se.Name, err)
+			}
+			se.Weight, se.HasWeight = float32(w), true
+		}
+		se.Data = columns.attributes(record, format.Attributes)
+		srcKind, dstKind := endKinds(se.Kind)
+		if _, ok := n.Nodes[se.SrcId]; !ok {
+			n.AddNode(&SimpleNoder{Name: se.SrcId, Kind: srcKind})
+		}
+		if _, ok := n.Nodes[se.DstId]; !ok {
+			n.AddNode(&SimpleNoder{Name: se.DstId, Kind: dstKind})
+		}
+		n.AddEdge(&se)
+	}
+}
+
+//csvRow builds the rows of the exported files, leaving out the empty columns of the format.
+type csvRow []string
+
+func (row csvRow) add(column, value string) csvRow {
+	if column == "" {
+		return row
+	}
+	return append(row, value)
+}
+
+func (row csvRow) addAttributes(data AttrGetter, names []string) csvRow {
+	for _, name := range names {
+		value, _ := exportedValue(data, name)
+		row = append(row, value)
+	}
+	return row
+}
+
+func writeRows(cw *csv.Writer, header csvRow, rows func(write func(csvRow))) error {
+	cw.Write(header)
+	rows(func(row csvRow) {
+		cw.Write(row)
+	})
+	cw.Flush()
+	return cw.Error()
+}
+
+//WriteNodeTable writes the nodes of the network.
+func (n *Network) WriteNodeTable(w io.Writer, format CSVFormat) error {
+	if format.Id == "" {
+		return fmt.Errorf("CSV ERROR: no column for the names of the nodes")
+	}
+	header := csvRow{}.add(format.Id, format.Id).add(format.Kind, format.Kind)
+	return writeRows(format.writer(w), append(header, format.Attributes...), func(write func(csvRow)) {
+		for _, node := range n.sortedNodes() {
+			write(csvRow{}.add(format.Id, node.Name).add(format.Kind, node.Kind.String()).addAttributes(node.NodeData, format.Attributes))
+		}
+	})
+}
+
+//WriteEdgeList writes the edges of the network.
+func (n *Network) WriteEdgeList(w io.Writer, format CSVFormat) error {
+	if format.Source == "" || format.Target == "" {
+		return fmt.Errorf("CSV ERROR: no column for the ends of the edges")
+	}
+	header := csvRow{}.add(format.Id, format.Id).add(format.Source, format.Source).add(format.Target, format.Target).
+		add(format.Kind, format.Kind).add(format.Weight, format.Weight)
+	return writeRows(format.writer(w), append(header, format.Attributes...), func(write func(csvRow)) {
+		for _, edge := range n.sortedEdges() {
+			write(csvRow{}.add(format.Id, edge.Name).add(format.Source, edge.Src.Name).add(format.Target, edge.Dst.Name).
+				add(format.Kind, edge.Kind.String()).add(format.Weight, strconv.FormatFloat(float64(edge.Weight), 'g', -1, 32)).
+				addAttributes(edge.LinkData, format.Attributes))
+		}
+	})
+}
+
+//ImportNodeTable adds the nodes of a node table file to the network.
+func (n *Network) ImportNodeTable(fp string, format CSVFormat) error {
+	return importFile(fp, func(r io.Reader) error {
+		return n.ReadNodeTable(r, format)
+	})
+}
+
+//ImportEdgeList adds the edges of an edge list file to the network.
+func (n *Network) ImportEdgeList(fp string, format CSVFormat) error {
+	return importFile(fp, func(r io.Reader) error {
+		return n.ReadEdgeList(r, format)
+	})
+}
+
+//ExportNodeTable writes the nodes of the network to a node table file.
+func (n *Network) ExportNodeTable(fp string, format CSVFormat) error {
+	return exportFile(fp, func(w io.Writer) error {
+		return n.WriteNodeTable(w, format)
+	})
+}
+
+//ExportEdgeList writes the edges of the network to an edge list file.
+func (n *Network) ExportEdgeList(fp string, format CSVFormat) error {
+	return exportFile(fp, func(w io.Writer) error {
+		return n.WriteEdgeList(w, format)
+	})
+}
@@ -0,0 +1,77 @@
+package go_nets
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	fmt.Println("### TESTING the CSV edge lists and node tables")
+	// An edge list from another team: its own columns, no kinds for some rows, and missing nodes
+	edges := "from\tto\ttype\tamount\tnote\n" +
+		"bank\tjohn.doe\tEmitter-Receiver\t2.5\tfirst\n" +
+		"bank\tother_bank\tEE\t\t\n" +
+		"jane.doe\tjohn.doe\t2\t1\tneighbours\n"
+	format := CSVFormat{'\t', "", "from", "to", "type", "amount", []string{"note"}}
+	network := NewNetwork("TestCSV", ioutil.Discard, testFolder)
+	network.AddNode(&SimpleNoder{Name: "bank", Kind: Emitter, Data: Attributes{"city": "Sacramento"}})
+	if err := network.ReadEdgeList(strings.NewReader(edges), format); err != nil {
+		t.Fatal(err)
+	}
+	if network.Nnodes != 4 || network.Nedges != 3 {
+		t.Fatalf("Got %d nodes and %d edges, expected 4 and 3", network.Nnodes, network.Nedges)
+	}
+	if e := network.Edges["bank_john.doe"]; e.Weight != 2.5 || e.Kind != ER || e.GetAttribute("note") != "first" {
+		t.Errorf("Unexpected edge %v", e)
+	}
+	if e := network.Edges["jane.doe_john.doe"]; e.Kind != RR || network.Nodes["jane.doe"].Kind != Receiver {
+		t.Errorf("Unexpected kinds for %v", e)
+	}
+	if e := network.Edges["bank_other_bank"]; e.Weight != DefaultWeight || e.Kind != EE || network.Nodes["other_bank"].Kind != Emitter || e.LinkData.(Attributes) != nil {
+		t.Errorf("Unexpected edge %v", e)
+	}
+	if err := network.ReadEdgeList(strings.NewReader("a\tb\n"), format); err == nil {
+		t.Error("Reading an edge list without the ends of the edges should fail")
+	}
+	if err := network.ReadEdgeList(strings.NewReader("from\tto\tamount\nx\ty\tmuch\n"), format); err == nil {
+		t.Error("Reading a malformed weight should fail")
+	}
+	// The short names of the kinds
+	if err := network.ReadNodeTable(strings.NewReader("name\tkind\nfiling_1\tH\n"), CSVFormat{'\t', "name", "", "", "kind", "", nil}); err != nil {
+		t.Fatal(err)
+	}
+	if node := network.Nodes["filing_1"]; node == nil || node.Kind != Hub {
+		t.Errorf("Unexpected node %v, expected a hub", node)
+	}
+	// Export and import with the default format
+	var nodes, edgeList bytes.Buffer
+	csvFormat := CSV
+	csvFormat.Attributes = []string{"city", "note"}
+	if err := network.WriteNodeTable(&nodes, csvFormat); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.WriteEdgeList(&edgeList, csvFormat); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Print(nodes.String(), edgeList.String())
+	network2 := NewNetwork("TestCSV2", ioutil.Discard, testFolder)
+	if err := network2.ReadNodeTable(&nodes, csvFormat); err != nil {
+		t.Fatal(err)
+	}
+	if err := network2.ReadEdgeList(&edgeList, csvFormat); err != nil {
+		t.Fatal(err)
+	}
+	for name, node := range network.Nodes {
+		if node2 := network2.Nodes[name]; node2 == nil || node2.Kind != node.Kind || node2.GetAttribute("city") != node.GetAttribute("city") {
+			t.Errorf("Node %q changed from %v to %v", name, node, node2)
+		}
+	}
+	for name, edge := range network.Edges {
+		if edge2 := network2.Edges[name]; edge2 == nil || edge2.Kind != edge.Kind || edge2.Weight != edge.Weight || edge2.GetAttribute("note") != edge.GetAttribute("note") {
+			t.Errorf("Edge %q changed from %v to %v", name, edge, edge2)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+//SQL dialects: what differs between the databases the SqlSaver and the Network persistence can target.
+//The queries are written with "?" placeholders, and rely on ON CONFLICT for the upserts.
+package go_nets
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+//Dialect hides the differences between the SQL databases. A database is designated by its location:
+//the path of its file for SQLite, or a path whose base name (without extension) is a schema for PostgreSQL.
+type Dialect interface {
+	Open(location string) (*sql.DB, error) // Opens the database, creating it if needed
+	Exists(location string) bool
+	Remove(location string) error
+	Rename(from, to string) error // Replaces the database at to, if any
+	Copy(from, to string) error
+	Rebind(query string) string                                               // Rewrites the "?" placeholders for the database
+	Dedupe(table string, columns ...string) string                            // Deletes the rows duplicated on the columns, keeping one
+	BulkLoad(tx *sql.Tx, table string, columns ...string) (BulkLoader, error) // Loads rows into a table
+}
+
+//BulkLoader adds rows to a table. The rows are only guaranteed to be written once it is closed.
+type BulkLoader interface {
+	Add(values ...interface{}) error
+	Close() error
+}
+
+var dialects = map[string]Dialect{
+	"sqlite3":  SQLite{},
+	"postgres": Postgres{},
+}
+
+//RegisterDialect sets the dialect used for a database driver, e.g. a Postgres dialect with its connection string.
+func RegisterDialect(driver string, d Dialect) {
+	dialects[driver] = d
+}
+
+func dialectOf(driver string) (Dialect, error) {
+	if d, ok := dialects[driver]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("DIALECT ERROR: no dialect for the database driver %q", driver)
+}
+
+//SQLite --
+
+//SQLite stores each database in its own file.
+type SQLite struct{}
+
+func (SQLite) Open(location string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", location)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (SQLite) Exists(location string) bool {
+	return fileExists(location)
+}
+
+func (SQLite) Remove(location string) error {
+	if err := os.Remove(location); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (SQLite) Rename(from, to string) error {
+	return os.Rename(from, to)
+}
+
+func (SQLite) Copy(from, to string) error {
+	return copyFile(from, to)
+}
+
+func (SQLite) Rebind(query string) string {
+	return query
+}
+
+func (SQLite) Dedupe(table string, columns ...string) string {
+	return "DELETE FROM " + table + " WHERE rowid NOT IN (SELECT MIN(rowid) FROM " + table + " GROUP BY " + strings.Join(columns, ", ") + ")"
+}
+
+func (SQLite) BulkLoad(tx *sql.Tx, table string, columns ...string) (BulkLoader, error) {
+	stmt, err := tx.Prepare("INSERT INTO " + table + "(" + strings.Join(columns, ", ") + ") values(" + placeholders(len(columns)) + ")")
+	if err != nil {
+		return nil, err
+	}
+	return insertLoader{stmt}, nil
+}
+
+type insertLoader struct {
+	stmt *sql.Stmt
+}
+
+func (l insertLoader) Add(values ...interface{}) error {
+	_, err := l.stmt.Exec(values...)
+	return err
+}
+
+func (l insertLoader) Close() error {
+	return l.stmt.Close()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := out.Close(); err == nil {
+			err = errClose
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+//PostgreSQL --
+
+//Postgres stores each database in a schema of the PostgreSQL database given by the DSN. The schema is
+//named after the base name of the location, without its extension (but the temporary suffix), the dots
+//being replaced by underscores: "data/Total.sqlite" is the schema "Total", "data/Total.tmp" the schema "Total_tmp".
+//An empty DSN uses the environment of libpq (PGHOST, PGDATABASE, PGUSER...).
+type Postgres struct {
+	DSN string
+}
+
+func (p Postgres) schema(location string) string {
+	base := filepath.Base(location)
+	if ext := filepath.Ext(base); ext != TempSuffix {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return strings.Replace(base, ".", "_", -1)
+}
+
+//dsn sets the search path of the connections to the schema, so that the queries don't have to qualify the tables.
+func (p Postgres) dsn(schema string) (string, error) {
+	if strings.HasPrefix(p.DSN, "postgres://") || strings.HasPrefix(p.DSN, "postgresql://") {
+		u, err := url.Parse(p.DSN)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("search_path", pq.QuoteIdentifier(schema))
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	return strings.TrimSpace(p.DSN+" search_path=") + quoteDSNValue(pq.QuoteIdentifier(schema)), nil
+}
+
+func quoteDSNValue(v string) string {
+	return "'" + strings.Replace(strings.Replace(v, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
+
+//admin opens a connection outside of any schema.
+func (p Postgres) admin() (*sql.DB, error) {
+	return sql.Open("postgres", p.DSN)
+}
+
+func (p Postgres) Open(location string) (*sql.DB, error) {
+	schema := p.schema(location)
+	dsn, err := p.dsn(schema)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(schema)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (p Postgres) Exists(location string) bool {
+	db, err := p.admin()
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_namespace WHERE nspname = $1)", p.schema(location)).Scan(&exists)
+	return err == nil && exists
+}
+
+func (p Postgres) Remove(location string) error {
+	return p.exec("DROP SCHEMA IF EXISTS " + pq.QuoteIdentifier(p.schema(location)) + " CASCADE")
+}
+
+func (p Postgres) Rename(from, to string) error {
+	return p.exec(
+		"DROP SCHEMA IF EXISTS "+pq.QuoteIdentifier(p.schema(to))+" CASCADE",
+		"ALTER SCHEMA "+pq.QuoteIdentifier(p.schema(from))+" RENAME TO "+pq.QuoteIdentifier(p.schema(to)),
+	)
+}
+
+//Copy copies the tables with their indexes and constraints, but without their foreign keys.
+func (p Postgres) Copy(from, to string) error {
+	db, err := p.admin()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = $1 AND table_type = 'BASE TABLE'", p.schema(from))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS " + pq.QuoteIdentifier(p.schema(to)) + " CASCADE",
+		"CREATE SCHEMA " + pq.QuoteIdentifier(p.schema(to)),
+	}
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return err
+		}
+		src := pq.QuoteIdentifier(p.schema(from)) + "." + pq.QuoteIdentifier(table)
+		dst := pq.QuoteIdentifier(p.schema(to)) + "." + pq.QuoteIdentifier(table)
+		stmts = append(stmts,
+			"CREATE TABLE "+dst+" (LIKE "+src+" INCLUDING ALL)",
+			"INSERT INTO "+dst+" SELECT * FROM "+src)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return p.exec(stmts...)
+}
+
+//exec executes the statements in one transaction.
+func (p Postgres) exec(stmts ...string) error {
+	db, err := p.admin()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%v: %s", err, stmt)
+		}
+	}
+	return tx.Commit()
+}
+
+func (Postgres) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (Postgres) Dedupe(table string, columns ...string) string {
+	conditions := []string{"a.ctid > b.ctid"}
+	for _, column := range columns {
+		conditions = append(conditions, "a."+column+" = b."+column)
+	}
+	return "DELETE FROM " + table + " a USING " + table + " b WHERE " + strings.Join(conditions, " AND ")
+}
+
+//BulkLoad uses COPY.
+func (Postgres) BulkLoad(tx *sql.Tx, table string, columns ...string) (BulkLoader, error) {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return nil, err
+	}
+	return copyLoader{stmt}, nil
+}
+
+type copyLoader struct {
+	stmt *sql.Stmt
+}
+
+func (l copyLoader) Add(values ...interface{}) error {
+	_, err := l.stmt.Exec(values...)
+	return err
+}
+
+func (l copyLoader) Close() error {
+	if _, err := l.stmt.Exec(); err != nil { // Flushes the rows
+		l.stmt.Close()
+		return err
+	}
+	return l.stmt.Close()
+}
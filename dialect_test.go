@@ -0,0 +1,79 @@
+package go_nets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPostgresDialect(t *testing.T) {
+	fmt.Println("### TESTING the PostgreSQL dialect")
+	p := Postgres{}
+	if q := p.Rebind("INSERT INTO debtors VALUES (?, ?) ON CONFLICT DO NOTHING"); q != "INSERT INTO debtors VALUES ($1, $2) ON CONFLICT DO NOTHING" {
+		t.Errorf("Got %q", q)
+	}
+	for location, expected := range map[string]string{
+		"_test/Total.sqlite":          "Total",
+		"_test/Total" + TempSuffix:    "Total_tmp",
+		"Network.sqlite" + TempSuffix: "Network_sqlite_tmp",
+	} {
+		if schema := p.schema(location); schema != expected {
+			t.Errorf("Got schema %q for %q, expected %q", schema, location, expected)
+		}
+	}
+	for dsn, expected := range map[string]string{
+		"":                            `search_path='"Total"'`,
+		"host=localhost dbname=ucc":   `host=localhost dbname=ucc search_path='"Total"'`,
+		"postgres://me@localhost/ucc": `postgres://me@localhost/ucc?search_path=%22Total%22`,
+	} {
+		if got, err := (Postgres{dsn}).dsn("Total"); err != nil || got != expected {
+			t.Errorf("Got %q (%v) for %q, expected %q", got, err, dsn, expected)
+		}
+	}
+}
+
+//TestPostgres runs against the server given by GO_NETS_POSTGRES_DSN, e.g. "host=localhost dbname=test sslmode=disable".
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("GO_NETS_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GO_NETS_POSTGRES_DSN not set")
+	}
+	fmt.Println("### TESTING the PostgreSQL backend")
+	RegisterDialect("postgres", Postgres{dsn})
+	defer RegisterDialect("postgres", Postgres{})
+	// The saver, twice to upsert
+	for _, mode := range []SaveMode{Rebuild, Append} {
+		c := make(chan Filing)
+		go func() {
+			for i := 0; i < 3; i++ {
+				c <- Filing{FileNumber: 137363375540 + i, Debtors: []Agent{{OrganizationName: `O"BRIEN`}}, Securers: []Agent{{OrganizationName: "BANK"}}}
+			}
+			close(c)
+		}()
+		saver := &SqlSaver{DbPath: testFolder, DbName: "TestPostgres", DBDriver: "postgres", Mode: mode}
+		if err := ListenAndSaveFilings(c, saver); err != nil || saver.Summary().Saved != 3 {
+			t.Fatalf("Got %v (%v), expected the 3 filings saved", saver.Summary(), err)
+		}
+	}
+	db, err := Postgres{dsn}.Open(testFolder + "TestPostgres.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow("SELECT COUNT(*) FROM debtors").Scan(&n); err != nil || n != 3 {
+		t.Errorf("Got %d debtors (%v), expected 3", n, err)
+	}
+	// The network
+	network := newTestNetwork("TestPostgres", [][3]string{{"a", "b", "ER"}, {"a", "c", "ER"}, {"a", "b", "ER"}})
+	network.DBDriver = "postgres"
+	network.Edges["a_b"].SetAttribute("note", "it's a test")
+	network.Save()
+	network2 := NewNetwork("TestPostgres2", ioutil.Discard, testFolder)
+	network2.DBDriver = "postgres"
+	network2.LoadFrom(testFolder + network.PersistingFile)
+	if network2.Nedges != 2 || network2.Edges["a_b"].Weight != 2 || network2.Edges["a_b"].GetAttribute("note") != "it's a test" {
+		t.Errorf("Got %d edges, expected the saved network", network2.Nedges)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Bloc Subfunctions
@@ -51,12 +52,147 @@ func (a *Agent) GetKind() NodeKind { // TODO: change to organisation/individual.
 }
 
 func (a *Agent) GetData() AttrGetter {
-	return a
+	return &AgentData{a.OrganizationName, a.IndividualName, []Location{a.location()}}
 }
 
-func (a *Agent) UpdateData(AttrGetter) AttrGetter {
-	// Create a more sophisticated attribute getter that can hold more data than that. Then, update with an additional address when there is some for the BUSINESSES only.
-	return a.GetData()
+// Merge the agent into the data already known for the same node: every distinct location is kept.
+// Data that doesn't come from an agent (e.g. attributes set by hand) is left untouched.
+func (a *Agent) UpdateData(data AttrGetter) AttrGetter {
+	ad, ok := data.(*AgentData)
+	if !ok {
+		return data
+	}
+	ad.AddLocation(a.location())
+	return ad
+}
+
+func (a *Agent) location() Location {
+	return Location{a.MailAddress, a.City, a.State, a.PostalCode, a.Country, a.FileNumber, a.FileNumber}
+}
+
+// Where an agent has been seen, with the (smallest and biggest) numbers of the filings it has been seen in.
+type Location struct {
+	MailAddress, City, State, PostalCode, Country string
+	FirstSeen, LastSeen                           int
+}
+
+func (l Location) sameAs(l2 Location) bool {
+	return strings.EqualFold(strings.TrimSpace(l.MailAddress), strings.TrimSpace(l2.MailAddress)) &&
+		strings.EqualFold(strings.TrimSpace(l.City), strings.TrimSpace(l2.City)) &&
+		strings.EqualFold(strings.TrimSpace(l.State), strings.TrimSpace(l2.State)) &&
+		strings.EqualFold(strings.TrimSpace(l.PostalCode), strings.TrimSpace(l2.PostalCode)) &&
+		strings.EqualFold(strings.TrimSpace(l.Country), strings.TrimSpace(l2.Country))
+}
+
+// The data of the agents and filings is saved with the network, and loaded back as is
+func init() {
+	RegisterData("agent", &AgentData{})
+	RegisterData("filing", FilingData{})
+}
+
+// Data of an agent node, accumulated over all the filings it appears in.
+type AgentData struct {
+	OrganizationName string
+	IndividualName   IndividualName
+	Locations        []Location
+}
+
+func (ad *AgentData) AddLocation(l Location) {
+	for i, known := range ad.Locations {
+		if known.sameAs(l) {
+			if l.FirstSeen < known.FirstSeen {
+				ad.Locations[i].FirstSeen = l.FirstSeen
+			}
+			if l.LastSeen > known.LastSeen {
+				ad.Locations[i].LastSeen = l.LastSeen
+			}
+			return
+		}
+	}
+	ad.Locations = append(ad.Locations, l)
+}
+
+// The most recently seen location
+func (ad *AgentData) Location() Location {
+	latest := Location{}
+	for i, l := range ad.Locations {
+		if i == 0 || l.LastSeen > latest.LastSeen {
+			latest = l
+		}
+	}
+	return latest
+}
+
+// The location attributes are the ones of the most recent location.
+func (ad *AgentData) GetAttribute(key string) interface{} {
+	switch key {
+	case "first_seen", "last_seen":
+		if len(ad.Locations) == 0 {
+			return nil
+		}
+		first, last := ad.Locations[0].FirstSeen, ad.Locations[0].LastSeen
+		for _, l := range ad.Locations {
+			if l.FirstSeen < first {
+				first = l.FirstSeen
+			}
+			if l.LastSeen > last {
+				last = l.LastSeen
+			}
+		}
+		if key == "first_seen" {
+			return first
+		}
+		return last
+	case "n_locations":
+		return len(ad.Locations)
+	}
+	l := ad.Location()
+	a := Agent{ad.OrganizationName, ad.IndividualName, l.MailAddress, l.City, l.State, l.PostalCode, l.Country, l.LastSeen}
+	return a.GetAttribute(key)
+}
+
+func (ad *AgentData) AttributeKeys() []string {
+	l := ad.Location()
+	a := Agent{ad.OrganizationName, ad.IndividualName, l.MailAddress, l.City, l.State, l.PostalCode, l.Country, l.LastSeen}
+	return append(a.AttributeKeys(), "first_seen", "last_seen", "n_locations")
+}
+
+// Define the agent as an AttrGetter
+func (a *Agent) GetAttribute(key string) interface{} {
+	switch key {
+	case "organization_name":
+		return a.OrganizationName
+	case "first_name":
+		return a.IndividualName.FirstName
+	case "middle_name":
+		return a.IndividualName.MiddleName
+	case "last_name":
+		return a.IndividualName.LastName
+	case "mail_address":
+		return a.MailAddress
+	case "city":
+		return a.City
+	case "state":
+		return a.State
+	case "postal_code":
+		return a.PostalCode
+	case "country":
+		return a.Country
+	}
+	return nil
+}
+
+var agentAttributeKeys = []string{"organization_name", "first_name", "middle_name", "last_name",
+	"mail_address", "city", "state", "postal_code", "country"}
+
+func (a *Agent) AttributeKeys() []string { // Only the filled ones
+	keys := []string{}
+	for _, k := range agentAttributeKeys {
+		if a.GetAttribute(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
 }
 
 // Create a new Edger from a Filing
@@ -67,7 +203,7 @@ type FilingEdger struct {
 }
 
 func (f *Filing) NewFilingEdger(kind EdgeKind, srcId string, dstId string) FilingEdger {
-	if dstId < srcId {
+	if (kind == EE || kind == RR) && dstId < srcId { // Only the symmetric kinds are sorted, ER edges keep their direction (secured party -> debtor)
 		temp := dstId
 		dstId = srcId
 		srcId = temp
@@ -91,21 +227,126 @@ func (fe FilingEdger) GetDstId() string {
 	return fe.dstId
 }
 
+func (fe FilingEdger) GetWeight() float32 { // One filing
+	return DefaultWeight
+}
+
+// The relationship starts with the filing (falling back on the original one) and ends when it lapses.
+// Dates that cannot be parsed leave the validity open.
+func (fe FilingEdger) GetValidity() (time.Time, time.Time) {
+	from, err := fe.filing.Date()
+	if err != nil {
+		from, _ = fe.filing.OriginalDate()
+	}
+	to, _ := fe.filing.Lapse()
+	return from, to
+}
+
 func (fe FilingEdger) GetData() AttrGetter {
-	return struct {
-		FileNumber, OriginalFileNumber    int
-		FileDate, OriginalFileDate        string
-		AmendmentType, FilingType, Method string
-	}{fe.filing.FileNumber, fe.filing.OriginalFileNumber,
-		fe.filing.FileDate, fe.filing.OriginalFileDate,
-		fe.filing.Amendment.Attr, fe.filing.FilingType.Attr, fe.filing.Method.Attr}
+	fileDate, _ := fe.filing.Date()
+	originalFileDate, _ := fe.filing.OriginalDate()
+	lapseDate, _ := fe.filing.Lapse()
+	return FilingData{fe.filing.FileNumber, fe.filing.OriginalFileNumber,
+		fileDate, originalFileDate, lapseDate,
+		fe.filing.Amendment.Attr, fe.filing.FilingType.Attr, fe.filing.Method.Attr,
+		0, time.Time{}}
+}
+
+// Data of the filing carried by the edges
+type FilingData struct {
+	FileNumber, OriginalFileNumber        int
+	FileDate, OriginalFileDate, LapseDate time.Time // Zero when missing or malformed
+	AmendmentType, FilingType, Method     string
+	LastAmendment                         int       // Set by the amendments (see Filing.Amend), 0 before
+	TerminationDate                       time.Time // Set by the terminations
+}
+
+func timeAttribute(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func (fd FilingData) GetAttribute(key string) interface{} {
+	switch key {
+	case "file_number":
+		return fd.FileNumber
+	case "original_file_number":
+		return fd.OriginalFileNumber
+	case "file_date":
+		return timeAttribute(fd.FileDate)
+	case "original_file_date":
+		return timeAttribute(fd.OriginalFileDate)
+	case "lapse_date":
+		return timeAttribute(fd.LapseDate)
+	case "amendment_type":
+		return fd.AmendmentType
+	case "filing_type":
+		return fd.FilingType
+	case "method":
+		return fd.Method
+	case "last_amendment":
+		if fd.LastAmendment == 0 {
+			return nil
+		}
+		return fd.LastAmendment
+	case "termination_date":
+		return timeAttribute(fd.TerminationDate)
+	}
+	return nil
+}
+
+var filingAttributeKeys = []string{"file_number", "original_file_number", "file_date", "original_file_date",
+	"lapse_date", "amendment_type", "filing_type", "method", "last_amendment", "termination_date"}
+
+func (fd FilingData) AttributeKeys() []string { // Only the defined ones
+	keys := []string{}
+	for _, k := range filingAttributeKeys {
+		if fd.GetAttribute(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// The filing itself as a node, for the bipartite dispatch
+type FilingNoder struct {
+	filing *Filing
 }
 
+// Same as the middle part of the identifiers of the FilingEdgers, so that projecting the
+// bipartite network gives back the edges of the clique dispatch.
+func (fn FilingNoder) GetIdentifier() string {
+	return strconv.Itoa(fn.filing.OriginalFileNumber)
+}
+
+func (fn FilingNoder) GetKind() NodeKind {
+	return Hub
+}
+
+func (fn FilingNoder) GetData() AttrGetter {
+	return FilingEdger{filing: fn.filing}.GetData()
+}
+
+func (fn FilingNoder) UpdateData(data AttrGetter) AttrGetter { // The original filing is kept
+	return data
+}
+
+// How the filings are turned into nodes and edges
+type DispatchMode int
+
+const (
+	CliqueDispatch    DispatchMode = iota // The parties of a filing are all connected together
+	BipartiteDispatch                     // The parties of a filing are connected to the filing (a Hub node), see Network.Project
+)
+
 // Define Filing as a Dispatcher
 func (f *Filing) Dispatch(logger *log.Logger) ([]Noder, []Edger) {
 	noders := []Noder{}
 	nodeIds := map[string]bool{} //For checking
 	edgers := []Edger{}
+	duplicates := []Agent{}
 	// First check duplicates... [See the code of clean() in the parser file]
 	// We have to do that now to prevent from sending the useless stuff over the wire to the network and log wrong warnings...
 	// It may be inefficient to do this kind of things at three different places (parser removes empty agents, here + Network check against existing data.)
@@ -114,9 +355,10 @@ func (f *Filing) Dispatch(logger *log.Logger) ([]Noder, []Edger) {
 		if i == len(f.Debtors) {
 			break
 		}
+		f.Debtors[i].FileNumber = f.FileNumber
 		d := f.Debtors[i]
 		if nodeIds[d.GetIdentifier()] {
-			// a.Data := a.UpdateData // Not implemented yet. (+ not straightforward implementation since there is no data field yet)
+			duplicates = append(duplicates, d) // Still sent as a node, to merge its data
 			logger.Println("DISPATCHER: removing debtor node", d.GetIdentifier(), "because of duplication.")
 			f.Debtors = DeleteAgent(f.Debtors, i)
 		} else {
@@ -129,9 +371,10 @@ func (f *Filing) Dispatch(logger *log.Logger) ([]Noder, []Edger) {
 		if i == len(f.Securers) {
 			break
 		}
+		f.Securers[i].FileNumber = f.FileNumber
 		s := f.Securers[i]
 		if nodeIds[s.GetIdentifier()] {
-			// a.Data := a.UpdateData // Not implemented yet. (+ not straightforward implementation since there is no data field yet)
+			duplicates = append(duplicates, s) // Still sent as a node, to merge its data
 			logger.Println("DISPATCHER: removing securer node", s.GetIdentifier(), "because of duplication.")
 			f.Securers = DeleteAgent(f.Securers, i)
 		} else {
@@ -140,6 +383,25 @@ func (f *Filing) Dispatch(logger *log.Logger) ([]Noder, []Edger) {
 		}
 	}
 	// Do the actual dispatching now that it's clean...
+	if f.Mode == BipartiteDispatch {
+		hub := FilingNoder{f}
+		noders = append(noders, hub)
+		for _, d := range f.Debtors {
+			d := d
+			noders = append(noders, &d)
+			edgers = append(edgers, f.NewFilingEdger(HR, hub.GetIdentifier(), d.GetIdentifier()))
+		}
+		for _, s := range f.Securers {
+			s := s
+			noders = append(noders, &s)
+			edgers = append(edgers, f.NewFilingEdger(EH, s.GetIdentifier(), hub.GetIdentifier()))
+		}
+		for _, a := range duplicates {
+			a := a
+			noders = append(noders, &a)
+		}
+		return noders, edgers
+	}
 	for i, d := range f.Debtors {
 		d := d
 		noders = append(noders, &d)
@@ -160,5 +422,149 @@ func (f *Filing) Dispatch(logger *log.Logger) ([]Noder, []Edger) {
 			edgers = append(edgers, f.NewFilingEdger(ER, s.GetIdentifier(), d.GetIdentifier()))
 		}
 	}
+	for _, a := range duplicates {
+		a := a
+		noders = append(noders, &a)
+	}
 	return noders, edgers
 }
+
+// Amendments --
+// An amendment updates the edges of its original filing (found through OriginalFileNumber)
+// instead of adding a new set of edges. The edges are never removed, their validity is closed.
+
+const InitialFiling = "Initial" // TransType of the filings that are not amendments
+
+// Amendment types and actions, as found in the AmendmentType and AmendmentAction elements
+const (
+	Termination        = "Termination"
+	Continuation       = "Continuation"
+	Assignment         = "Assignment"
+	DebtorDelete       = "DebtorDelete"
+	SecuredPartyDelete = "SecuredPartyDelete"
+)
+
+func (f *Filing) IsAmendment() bool {
+	if t := strings.TrimSpace(f.FilingType.Attr); t != "" {
+		return !strings.EqualFold(t, InitialFiling)
+	}
+	return f.OriginalFileNumber != 0 && f.FileNumber != f.OriginalFileNumber
+}
+
+// Has tells if the amendment is of the given type or contains the given action.
+func (f *Filing) Has(kind string) bool {
+	if strings.EqualFold(strings.TrimSpace(f.Amendment.Attr), kind) {
+		return true
+	}
+	for _, t := range f.AmendmentTypes {
+		if strings.EqualFold(strings.TrimSpace(t.Attr), kind) {
+			return true
+		}
+	}
+	for _, a := range f.AmendmentActions {
+		if strings.EqualFold(strings.TrimSpace(a.Attr), kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Edges of the original filing, found in the index of the network: the bare terminations list no parties.
+func (f *Filing) originalEdges(n *Network) []*Edge {
+	return append([]*Edge{}, n.originals[f.OriginalFileNumber]...)
+}
+
+// Define Filing as an Amender
+func (f *Filing) Amend(n *Network) bool {
+	if !f.IsAmendment() {
+		return false
+	}
+	original := f.originalEdges(n)
+	if len(original) == 0 {
+		n.Logger.Printf("AMEND WARNING: original filing %d of amendment %d not found, dispatching it as a new filing", f.OriginalFileNumber, f.FileNumber)
+		return false
+	}
+	date, err := f.Date()
+	if err != nil {
+		n.Logger.Printf("AMEND WARNING: no validity closed by amendment %d: %s", f.FileNumber, err)
+	}
+	closeEdge := func(e *Edge) {
+		if !date.IsZero() && (e.ValidTo.IsZero() || date.Before(e.ValidTo)) {
+			e.ValidTo = date
+		}
+	}
+	noders, edgers := f.Dispatch(n.Logger)
+	for _, noder := range noders {
+		n.AddNode(noder)
+	}
+	debtors, securers := map[*Node]bool{}, map[*Node]bool{}
+	for _, d := range f.Debtors {
+		debtors[n.Nodes[d.GetIdentifier()]] = true
+	}
+	for _, s := range f.Securers {
+		securers[n.Nodes[s.GetIdentifier()]] = true
+	}
+	// The amendment is recorded in the data of the edges, the FilingData keeping its type
+	record := func(e *Edge, termination time.Time) {
+		if fd, ok := e.LinkData.(FilingData); ok {
+			fd.LastAmendment = f.FileNumber
+			if !termination.IsZero() {
+				fd.TerminationDate = termination
+			}
+			e.LinkData = fd
+			return
+		}
+		e.SetAttribute("last_amendment", f.FileNumber)
+		if !termination.IsZero() {
+			e.SetAttribute("termination_date", termination)
+		}
+	}
+	pairs := map[[2]string]bool{}
+	for _, e := range original {
+		pairs[[2]string{e.Src.Name, e.Dst.Name}] = true
+		if !f.Has(Termination) {
+			record(e, time.Time{})
+		}
+	}
+	switch {
+	case f.Has(Termination):
+		for _, e := range original {
+			closeEdge(e)
+			record(e, date)
+		}
+		return true
+	case f.Has(Continuation): // New lapse date for the edges still valid
+		lapse, err := f.Lapse()
+		if err != nil {
+			n.Logger.Printf("AMEND WARNING: validity of filing %d not continued by amendment %d: %s", f.OriginalFileNumber, f.FileNumber, err)
+			return true
+		}
+		for _, e := range original {
+			if date.IsZero() || e.ActiveAt(date) {
+				e.ValidTo = lapse
+			}
+		}
+		return true
+	case f.Has(DebtorDelete) || f.Has(SecuredPartyDelete):
+		for _, e := range original {
+			if (f.Has(DebtorDelete) && (debtors[e.Src] || debtors[e.Dst])) ||
+				(f.Has(SecuredPartyDelete) && (securers[e.Src] || securers[e.Dst])) {
+				closeEdge(e)
+			}
+		}
+		return true
+	case f.Has(Assignment): // The secured parties of the original filing not listed anymore are replaced by the listed ones
+		for _, e := range original {
+			if ((e.Kind == ER || e.Kind == EH) && !securers[e.Src]) || (e.Kind == EE && !(securers[e.Src] && securers[e.Dst])) {
+				closeEdge(e)
+			}
+		}
+	}
+	// Parties added or changed: connect the pairs that were not in the original filing
+	for _, edger := range edgers {
+		if !pairs[[2]string{edger.GetSrcId(), edger.GetDstId()}] {
+			n.AddEdge(edger)
+		}
+	}
+	return true
+}
@@ -3,9 +3,12 @@ package go_nets
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"code.google.com/p/go.text/encoding/charmap"
 
@@ -71,3 +74,215 @@ func TestDispatcher(t *testing.T) {
 		ShowOutputs(&p, nil)
 	}
 }
+
+func TestFilingEdgerDirection(t *testing.T) {
+	f := &Filing{OriginalFileNumber: 1}
+	if e := f.NewFilingEdger(ER, "zbank", "adebtor"); e.GetSrcId() != "zbank" || e.GetDstId() != "adebtor" {
+		t.Errorf("ER edges should go from the secured party to the debtor, got %s -> %s", e.GetSrcId(), e.GetDstId())
+	}
+	if e := f.NewFilingEdger(EE, "zbank", "abank"); e.GetSrcId() != "abank" {
+		t.Errorf("EE edges should be sorted, got %s -> %s", e.GetSrcId(), e.GetDstId())
+	}
+}
+
+// Build a filing in memory: secured parties are organizations, debtors are individuals (first.last)
+func newTestFiling(fileNumber int, securers []string, debtors []string) Filing {
+	f := Filing{FileNumber: fileNumber, OriginalFileNumber: fileNumber,
+		FileDate: "20130522 1700", OriginalFileDate: "20130522 1700"}
+	f.FilingType.Attr = "Initial"
+	for _, s := range securers {
+		f.Securers = append(f.Securers, Agent{OrganizationName: s, City: "San Francisco", State: "CA", PostalCode: "94102"})
+	}
+	for _, d := range debtors {
+		names := strings.SplitN(d, ".", 2)
+		f.Debtors = append(f.Debtors, Agent{IndividualName: IndividualName{FirstName: names[0], LastName: names[1]},
+			City: "Sacramento", State: "CA", PostalCode: "94280"})
+	}
+	return f
+}
+
+func TestAgentMerge(t *testing.T) {
+	fmt.Println("### TESTING the merging of agents")
+	network := NewNetwork("TestAgentMerge", ioutil.Discard, testFolder)
+	filings := []Filing{
+		newTestFiling(3, []string{"BANK"}, []string{"john.doe"}),
+		newTestFiling(1, []string{"BANK"}, []string{"jane.doe"}),
+		newTestFiling(2, []string{"BANK"}, []string{"jim.doe"}),
+	}
+	filings[1].Securers[0].City, filings[1].Securers[0].MailAddress = "Oakland", "1 BROADWAY"
+	filings[2].Securers[0].City = "san francisco " // Same location, written differently
+	for i := range filings {
+		network.AddDispatcher(&filings[i])
+	}
+	ad, ok := network.Nodes["bank"].NodeData.(*AgentData)
+	if !ok {
+		t.Fatalf("Unexpected data for the bank: %#v", network.Nodes["bank"].NodeData)
+	}
+	if len(ad.Locations) != 2 {
+		t.Fatalf("Got %d locations, expected 2: %+v", len(ad.Locations), ad.Locations)
+	}
+	if l := ad.Locations[0]; l.City != "San Francisco" || l.FirstSeen != 2 || l.LastSeen != 3 {
+		t.Errorf("Unexpected first location %+v", l)
+	}
+	if l := ad.Locations[1]; l.City != "Oakland" || l.FirstSeen != 1 || l.LastSeen != 1 {
+		t.Errorf("Unexpected second location %+v", l)
+	}
+	if first, _ := GetInt(ad, "first_seen"); first != 1 {
+		t.Errorf("Got first_seen %d, expected 1", first)
+	}
+	if city, _ := GetString(ad, "city"); city != "San Francisco" {
+		t.Errorf("Got the current city %q, expected San Francisco", city)
+	}
+	// Duplicates within a filing are merged too
+	f := newTestFiling(4, []string{"BANK", "BANK"}, []string{"john.doe"})
+	f.Securers[1].City = "Fresno"
+	network.AddDispatcher(&f)
+	if len(ad.Locations) != 3 {
+		t.Errorf("Got %d locations after a duplicated secured party, expected 3", len(ad.Locations))
+	}
+}
+
+func TestAmendments(t *testing.T) {
+	fmt.Println("### TESTING the amendments")
+	network := NewNetwork("TestAmendments", ioutil.Discard, testFolder)
+	day := func(date string) time.Time {
+		d, _ := ParseFilingDate(date)
+		return d
+	}
+	amendment := func(fileNumber, originalFileNumber int, date string, amendmentType string, securers []string, debtors []string) Filing {
+		f := newTestFiling(fileNumber, securers, debtors)
+		f.OriginalFileNumber = originalFileNumber
+		f.FileDate = date
+		f.FilingType.Attr = "Amendment"
+		f.Amendment.Attr = amendmentType
+		return f
+	}
+	filings := []Filing{
+		newTestFiling(1, []string{"BANK"}, []string{"john.doe", "jane.doe"}),
+		newTestFiling(10, []string{"BANK", "OTHER BANK"}, []string{"jim.doe"}),
+		newTestFiling(20, []string{"THIRD BANK"}, []string{"joe.doe"}),
+		amendment(2, 1, "20140101 1200", Termination, []string{}, []string{}),
+		amendment(11, 10, "20140101 1200", Assignment, []string{"NEW BANK"}, []string{"jim.doe"}),
+		amendment(21, 20, "20140101 1200", "AmendmentParties", []string{"THIRD BANK"}, []string{"joe.doe", "joey.doe"}),
+		amendment(31, 30, "20140101 1200", Termination, []string{"BANK"}, []string{"jack.doe"}),
+	}
+	filings[1].LapseDate = "20180522"
+	filings = append(filings, amendment(12, 10, "20170101 1200", Continuation, []string{}, []string{"jim.doe"}))
+	filings[len(filings)-1].LapseDate = "20230522"
+	filings = append(filings, newTestFiling(40, []string{"BANK"}, []string{"jill.doe"}),
+		amendment(41, 40, "20170101 1200", Continuation, []string{}, []string{"jill.doe"}))
+	filings[len(filings)-2].LapseDate = "20200101"
+	filings[len(filings)-1].LapseDate = "" // Missing lapse date
+	for i := range filings {
+		network.AddDispatcher(&filings[i])
+	}
+	before, after := network.AsOf(day("20131231")), network.AsOf(day("20140102"))
+	// Termination
+	for _, id := range []string{"bank_1_john.doe94280", "bank_1_jane.doe94280", "jane.doe94280_1_john.doe94280"} {
+		if before.Edges[id] == nil || after.Edges[id] != nil {
+			t.Errorf("Edge %q should be terminated at the beginning of 2014", id)
+		}
+	}
+	// Assignment
+	if before.Edges["bank_10_jim.doe94280"] == nil || after.Edges["bank_10_jim.doe94280"] != nil || after.Edges["bank_10_other_bank"] != nil {
+		t.Error("The secured parties of filing 10 should be replaced by the assignee")
+	}
+	if before.Edges["new_bank_10_jim.doe94280"] != nil || after.Edges["new_bank_10_jim.doe94280"] == nil {
+		t.Error("The assignee should be linked to the debtor of filing 10 from the assignment on")
+	}
+	// Continuation
+	if e := network.Edges["new_bank_10_jim.doe94280"]; !e.ValidTo.Equal(day("20230522")) {
+		t.Errorf("The continuation should push the lapse date to 2023, got %v", e.ValidTo)
+	}
+	if e := network.Edges["bank_10_jim.doe94280"]; !e.ValidTo.Equal(day("20140101 1200")) {
+		t.Errorf("The continuation should not reopen the assigned edge, got %v", e.ValidTo)
+	}
+	if e := network.Edges["bank_40_jill.doe94280"]; !e.ValidTo.Equal(day("20200101")) {
+		t.Errorf("A continuation without lapse date should leave the validity as it is, got %v", e.ValidTo)
+	}
+	// The amendments are recorded in the filing data
+	if fd, ok := network.Edges["bank_1_john.doe94280"].LinkData.(FilingData); !ok {
+		t.Errorf("The terminated edge should keep its FilingData, got %T", network.Edges["bank_1_john.doe94280"].LinkData)
+	} else if fd.LastAmendment != 2 || !fd.TerminationDate.Equal(day("20140101 1200")) {
+		t.Errorf("The termination should be recorded in the filing data, got %+v", fd)
+	}
+	if fd, ok := network.Edges["bank_10_jim.doe94280"].LinkData.(FilingData); !ok || fd.LastAmendment != 12 {
+		t.Errorf("The continuation should be recorded in the filing data, got %#v", network.Edges["bank_10_jim.doe94280"].LinkData)
+	}
+	// Parties added
+	if e := network.Edges["third_bank_20_joey.doe94280"]; e == nil || !e.ValidFrom.Equal(day("20140101 1200")) {
+		t.Errorf("The added debtor should be linked from the date of the amendment, got %v", e)
+	}
+	if e := network.Edges["third_bank_20_joe.doe94280"]; e.Weight != 1 {
+		t.Errorf("Listing the parties again should not count as a new filing, got weight %v", e.Weight)
+	}
+	// Unknown original filing
+	if e := network.Edges["bank_30_jack.doe94280"]; e == nil {
+		t.Error("An amendment of an unknown filing should be dispatched as a new filing")
+	}
+	// The originals are indexed, removals included
+	original := &Filing{OriginalFileNumber: 20}
+	if edges := original.originalEdges(&network); len(edges) != 3 {
+		t.Errorf("Got %d edges for filing 20, expected 3", len(edges))
+	}
+	network.RemoveEdge("third_bank_20_joe.doe94280")
+	if edges := original.originalEdges(&network); len(edges) != 2 {
+		t.Errorf("Got %d edges for filing 20 after a removal, expected 2", len(edges))
+	}
+	after.Summary(os.Stdout)
+}
+
+func TestBipartite(t *testing.T) {
+	fmt.Println("### TESTING the bipartite dispatch and its projection")
+	filings := func() []Filing {
+		return []Filing{
+			newTestFiling(1, []string{"BANK", "OTHER BANK"}, []string{"john.doe", "jane.doe", "jim.doe"}),
+			newTestFiling(2, []string{"BANK"}, []string{"john.doe"}),
+			newTestFiling(3, []string{"THIRD BANK"}, []string{"jack.doe", "joe.doe"}),
+		}
+	}
+	clique := NewNetwork("TestClique", ioutil.Discard, testFolder)
+	for _, f := range filings() {
+		f := f
+		clique.AddDispatcher(&f)
+	}
+	bipartite := NewNetwork("TestBipartite", ioutil.Discard, testFolder)
+	bipartite.Symmetrical = false
+	for _, f := range filings() {
+		f := f
+		f.Mode = BipartiteDispatch
+		bipartite.AddDispatcher(&f)
+	}
+	bipartite.Summary(os.Stdout)
+	if bipartite.Nnodes != clique.Nnodes+3 || bipartite.Nedges != 5+2+3 {
+		t.Errorf("Got %d nodes and %d edges in the bipartite network", bipartite.Nnodes, bipartite.Nedges)
+	}
+	if hub := bipartite.Nodes["1"]; hub == nil || hub.Kind != Hub || hub.OutDegree() != 3 || hub.InDegree() != 2 {
+		t.Errorf("Filing 1 should be a hub with 2 secured parties and 3 debtors, got %v", hub)
+	}
+	projected := bipartite.Project(Hub)
+	projected.Summary(os.Stdout)
+	if projected.Nnodes != clique.Nnodes || projected.Nedges != clique.Nedges {
+		t.Errorf("Got %d nodes and %d edges in the projection, expected %d and %d", projected.Nnodes, projected.Nedges, clique.Nnodes, clique.Nedges)
+	}
+	for name, e := range clique.Edges {
+		e2, ok := projected.Edges[name]
+		if !ok || e2.Kind != e.Kind || e2.Src.Name != e.Src.Name || e2.Dst.Name != e.Dst.Name || e2.Weight != e.Weight {
+			t.Errorf("Edge %q of the clique network is not in the projection: %v", name, e2)
+		}
+		if n, _ := GetInt(e2.LinkData, "file_number"); ok && n != e.LinkData.GetAttribute("file_number") {
+			t.Errorf("Edge %q carries the data of filing %d", name, n)
+		}
+	}
+	checkAdjacency(t, &projected)
+	// Amendments work on the hubs too
+	termination := newTestFiling(4, []string{}, []string{"jack.doe"})
+	termination.OriginalFileNumber, termination.FileDate = 3, "20140101 1200"
+	termination.FilingType.Attr, termination.Amendment.Attr = "Amendment", Termination
+	bipartite.AddDispatcher(&termination)
+	asOf := bipartite.AsOf(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	snapshot := asOf.Project(Hub)
+	if snapshot.Edges["third_bank_3_jack.doe94280"] != nil || snapshot.Edges["bank_1_jane.doe94280"] == nil {
+		t.Error("The terminated filing should not be in the projection of 2015")
+	}
+}
@@ -0,0 +1,132 @@
+package go_nets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//Graphviz DOT --
+//A subnetwork (e.g. from DetectSubs) is rendered with the emitters as boxes and the receivers as ellipses,
+//the edges being styled after their kind. The nodes can be clustered by component (e.g. with Network.Components).
+
+//DotOptions tunes the rendering of a subnetwork.
+type DotOptions struct {
+	Components map[*Node]int      // Clusters the nodes by component (e.g. Components.Of), when not nil. The nodes missing from it are left out of the clusters
+	Label      func(*Node) string // Label of the nodes. nil for their names
+}
+
+var (
+	dotShapes = map[NodeKind]string{
+		Emitter:  "box",
+		Receiver: "ellipse",
+		Hub:      "diamond",
+	}
+	dotStyles = map[EdgeKind]string{
+		ER: "solid",
+		EE: "dashed",
+		RR: "dotted",
+		EH: "bold",
+		HR: "bold",
+	}
+)
+
+func dotQuote(s string) string {
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+//WriteDot renders the nodes of the subnetwork, and the edges between them. A nil subnetwork renders the whole network.
+func (n *Network) WriteDot(w io.Writer, subNetwork map[*Node]bool, options DotOptions) error {
+	bw := bufio.NewWriter(w)
+	graph, edgeOp := "digraph", "->"
+	if n.Symmetrical {
+		graph, edgeOp = "graph", "--"
+	}
+	fmt.Fprintf(bw, "%s %s {\n", graph, dotQuote(n.Name))
+	writeNode := func(indent string, node *Node) {
+		label := node.Name
+		if options.Label != nil {
+			label = options.Label(node)
+		}
+		fmt.Fprintf(bw, "%s%s [label=%s, shape=%s];\n", indent, dotQuote(node.Name), dotQuote(label), dotShapes[node.Kind])
+	}
+	// The nodes, in their clusters
+	clusters := map[int][]*Node{}
+	for _, node := range n.dotNodes(subNetwork) {
+		if iSub, ok := options.Components[node]; ok {
+			clusters[iSub] = append(clusters[iSub], node)
+		} else {
+			writeNode("  ", node)
+		}
+	}
+	iSubs := []int{}
+	for iSub := range clusters {
+		iSubs = append(iSubs, iSub)
+	}
+	sort.Ints(iSubs)
+	for _, iSub := range iSubs {
+		fmt.Fprintf(bw, "  subgraph cluster_%d {\n    label=%s;\n", iSub, dotQuote(fmt.Sprint("Component ", iSub)))
+		for _, node := range clusters[iSub] {
+			writeNode("    ", node)
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+	// The edges
+	for _, edge := range n.dotEdges(subNetwork) {
+		fmt.Fprintf(bw, "  %s %s %s [style=%s, tooltip=%s];\n", dotQuote(edge.Src.Name), edgeOp, dotQuote(edge.Dst.Name),
+			dotStyles[edge.Kind], dotQuote(fmt.Sprintf("%s (%s, %g)", edge.Name, edge.Kind, edge.Weight)))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+//dotNodes returns the nodes of the subnetwork, sorted by name, without going through the whole network.
+func (n *Network) dotNodes(subNetwork map[*Node]bool) []*Node {
+	if subNetwork == nil {
+		return n.sortedNodes()
+	}
+	nodes := make([]*Node, 0, len(subNetwork))
+	for node, in := range subNetwork {
+		if in {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+//dotEdges returns the edges between the nodes of the subnetwork, sorted by name, collected from the adjacency lists of these nodes.
+func (n *Network) dotEdges(subNetwork map[*Node]bool) []*Edge {
+	if subNetwork == nil {
+		return n.sortedEdges()
+	}
+	seen := map[*Edge]bool{}
+	edges := []*Edge{}
+	collect := func(etns []*EdgeToNode) {
+		for _, etn := range etns {
+			if !seen[etn.Edge] && subNetwork[etn.Src] && subNetwork[etn.Dst] {
+				seen[etn.Edge] = true
+				edges = append(edges, etn.Edge)
+			}
+		}
+	}
+	for node, in := range subNetwork {
+		if in {
+			collect(node.Edges)
+			if !n.Symmetrical {
+				collect(node.InEdges)
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Name < edges[j].Name })
+	return edges
+}
+
+//ExportDot writes the rendering of the subnetwork to a DOT file.
+func (n *Network) ExportDot(fp string, subNetwork map[*Node]bool, options DotOptions) error {
+	return exportFile(fp, func(w io.Writer) error {
+		return n.WriteDot(w, subNetwork, options)
+	})
+}
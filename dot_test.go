@@ -0,0 +1,53 @@
+package go_nets
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDot(t *testing.T) {
+	fmt.Println("### TESTING the DOT rendering")
+	network := newTestNetwork("TestDot", [][3]string{{"a", "b", "ER"}, {"b", "c", "EE"}, {"d", "e", "RR"}})
+	network.Nodes["b"].Kind = Receiver
+	sub, _ := DetectSubs(network.Nodes["a"], 10)
+	var b bytes.Buffer
+	if err := network.WriteDot(&b, sub, DotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Print(b.String())
+	dot := b.String()
+	for _, expected := range []string{`graph "TestDot" {`, `"a" [label="a", shape=box];`, `"b" [label="b", shape=ellipse];`, `"a" -- "b" [style=solid`, `"b" -- "c" [style=dashed`} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Missing %q in the rendering", expected)
+		}
+	}
+	if strings.Contains(dot, `"d"`) {
+		t.Error("Node d is not in the subnetwork")
+	}
+	// The whole network, clustered by component
+	b.Reset()
+	network.WriteDot(&b, nil, DotOptions{Components: network.Components().Of, Label: func(n *Node) string { return strings.ToUpper(n.Name) }})
+	fmt.Print(b.String())
+	if dot = b.String(); strings.Count(dot, "subgraph cluster_") != 2 || !strings.Contains(dot, `"d" -- "e" [style=dotted`) || !strings.Contains(dot, `label="E"`) {
+		t.Error("Unexpected rendering of the components")
+	}
+	// A directed network: the edges of the subnetwork are rendered once, whatever their direction
+	directed := NewNetwork("TestDotDirected", ioutil.Discard, testFolder)
+	directed.Symmetrical = false
+	addTestEdges(&directed, [][3]string{{"a", "b", "ER"}, {"c", "a", "EE"}, {"b", "c", "RR"}, {"c", "d", "ER"}})
+	b.Reset()
+	directed.WriteDot(&b, map[*Node]bool{directed.Nodes["a"]: true, directed.Nodes["b"]: true, directed.Nodes["c"]: true}, DotOptions{})
+	fmt.Print(b.String())
+	dot = b.String()
+	for _, expected := range []string{`"a" -> "b"`, `"c" -> "a"`, `"b" -> "c"`} {
+		if strings.Count(dot, expected) != 1 {
+			t.Errorf("Got %d %q in the rendering, expected 1", strings.Count(dot, expected), expected)
+		}
+	}
+	if strings.Contains(dot, `"d"`) || strings.Index(dot, `"a" -> "b"`) > strings.Index(dot, `"b" -> "c"`) {
+		t.Error("Unexpected rendering of the directed subnetwork")
+	}
+}
@@ -96,21 +96,17 @@ func Save(network *go_nets.Network) {
 
 func Parse(fileNames []string, network *go_nets.Network) {
 	//Prepare the parsers and channels
-	parsers := []go_nets.XmlParser{}
+	parsers := []go_nets.FilingParser{}
 	out := make(chan go_nets.Filing)
 	cs := []chan go_nets.Filing{}
 	for _, fileName := range fileNames {
-		parsers = append(parsers, go_nets.XmlParser{
-			FileDir:  *parsePathArg,
-			FileName: fileName, // "UM20140215_" + strconv.Itoa(i),
-			Encoding: charmap.Windows1252,
-		})
-		_ = fileName
+		// XML or JSON Lines, depending on the extension
+		parsers = append(parsers, go_nets.NewFilingParser(*parsePathArg, fileName, charmap.Windows1252))
 		cs = append(cs, make(chan go_nets.Filing))
 	}
 	//Launch the parsers
 	for i, parser := range parsers {
-		fi, errOs := os.Create(network.Folder + parser.FileName + ".log")
+		fi, errOs := os.Create(network.Folder + fileNames[i] + ".log")
 		if errOs != nil {
 			panic(errOs) //TODO change it to t.Error
 		}
@@ -119,7 +115,14 @@ func Parse(fileNames []string, network *go_nets.Network) {
 				panic(errOs)
 			}
 		}()
-		go parser.Parse(cs[i], fi)
+		parser, ci := parser, cs[i]
+		go func() {
+			report, err := parser.Parse(ci, fi)
+			if err != nil {
+				log.Println("Parsing failed:", err)
+			}
+			log.Println(report)
+		}()
 	}
 
 	//Launch fan in
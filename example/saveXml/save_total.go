@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
 
@@ -39,9 +41,13 @@ var (
 	nameArg      = flag.String("name", "Total", "Provide the name of the database")
 	batchSizeArg = flag.Int("batchSize", 50000, "Provide the size of the saving batches.")
 	nCores       = flag.Int("nCores", 4, "Provide the number of cores for multi-threading.")
+	resumeArg    = flag.Bool("resume", false, "Resume an interrupted saving, skipping the filings already saved.")
+	appendArg    = flag.Bool("append", false, "Add the filings to the existing database instead of rebuilding it.")
+	driverArg    = flag.String("driver", "sqlite3", "Provide the database driver: sqlite3 or postgres.")
+	dsnArg       = flag.String("dsn", "", "Provide the connection string of the postgres database, the filings being saved in the schema named after -name.")
 )
 
-const usageMsg string = "save_total -parsePath=[] -parse=[,] -name=[] -savePathe=[]\n"
+const usageMsg string = "save_total -parsePath=[] -parse=[,] -name=[] -savePathe=[] [-resume | -append]\n"
 
 func init() {
 	flag.Var(&parseArgs, "parse", "Specify a comma separated list of file names for parsing")
@@ -57,7 +63,7 @@ func usage() {
 ////////////
 //SECTION 2
 //Utilities
-func merge(cs [](chan go_nets.Filing), out chan<- go_nets.Filing) {
+func merge(ctx context.Context, cs [](chan go_nets.Filing), out chan<- go_nets.Filing) {
 	done := make(chan int)
 	for ic, c := range cs {
 		ic := ic
@@ -66,7 +72,10 @@ func merge(cs [](chan go_nets.Filing), out chan<- go_nets.Filing) {
 			for p := range c {
 				_ = ic
 				// fmt.Println("Merging in", p, "from channel", ic)
-				out <- p
+				select {
+				case out <- p:
+				case <-ctx.Done(): // Drop it, the parsers are stopping
+				}
 			}
 			// fmt.Println("Sending done for channel", ic)
 			done <- 1
@@ -97,35 +106,34 @@ func closeFile(fi *os.File) {
 ////////////
 //SECTION 3
 //Subsections
-func Parse(fileNames []string) chan go_nets.Filing {
+func Parse(ctx context.Context, fileNames []string, checkpoints map[string]int) chan go_nets.Filing {
 	//Prepare the parsers and channels
-	parsers := []go_nets.XmlParser{}
+	parsers := []go_nets.FilingParser{}
 	out := make(chan go_nets.Filing, *batchSizeArg)
 	cs := []chan go_nets.Filing{}
 	for _, fileName := range fileNames {
-		fileName := fileName
-		parsers = append(parsers, go_nets.XmlParser{
-			FileDir:  *parsePathArg,
-			FileName: fileName, // "UM20140215_" + strconv.Itoa(i),
-			Encoding: charmap.Windows1252,
-		})
-		// _ = fileName
+		// XML or JSON Lines, depending on the extension. Skip the filings saved by a previous run
+		parsers = append(parsers, go_nets.ResumeFilingParser(*parsePathArg, fileName, charmap.Windows1252, checkpoints[fileName]))
 		cs = append(cs, make(chan go_nets.Filing))
 	}
 	//Launch the parsers
 	for i, parser := range parsers {
 		parser := parser
-		fmt.Println("Starting parsing for file " + parser.FileName)
-		fi := openFile(*savePathArg + parser.FileName + ".log")
+		fmt.Println("Starting parsing for file " + fileNames[i])
+		fi := openFile(*savePathArg + fileNames[i] + ".log")
 		csi := cs[i]
 		go func() {
-			parser.Parse(csi, fi)
+			report, err := parser.ParseContext(ctx, csi, fi)
+			if err != nil {
+				log.Println("Parsing failed:", err)
+			}
+			log.Println(report)
 			closeFile(fi)
 		}()
 	}
 
 	//Launch fan in
-	go merge(cs, out)
+	go merge(ctx, cs, out)
 	// out = cs[0]
 
 	return out
@@ -148,14 +156,50 @@ func main() {
 
 	log.SetOutput(io.MultiWriter(os.Stdout, fiLog))
 
+	//The filings rejected by the database are kept aside
+	fiRejected := openFile(*savePathArg + *nameArg + ".rejected.jsonl")
+	defer closeFile(fiRejected)
+
 	//Prepare the sql saver
 	saver := &go_nets.SqlSaver{
-		DbPath:   *savePathArg,
-		DbName:   *nameArg,
-		DBDriver: "sqlite3",
+		DbPath:      *savePathArg,
+		DbName:      *nameArg,
+		DBDriver:    *driverArg,
+		DeadLetters: go_nets.DeadLetterWriter{Writer: fiRejected},
+	}
+	if *driverArg == "postgres" {
+		go_nets.RegisterDialect("postgres", go_nets.Postgres{DSN: *dsnArg})
+	}
+	switch {
+	case *resumeArg && *appendArg:
+		usage()
+	case *resumeArg:
+		saver.Mode = go_nets.Resume
+	case *appendArg:
+		saver.Mode = go_nets.Append
+	}
+	checkpoints, err := saver.Checkpoints()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for fileName, records := range checkpoints {
+		log.Printf("Resuming %s after %d filings", fileName, records)
 	}
 
+	//Stop cleanly on Ctrl-C: the committed batches are kept in the temporary database
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		<-sigCh
+		log.Println("Interrupted, stopping...")
+		cancel()
+	}()
+
 	//Launch It
-	go_nets.ListenAndSaveFilings(Parse(parseArgs), saver)
+	if err := go_nets.ListenAndSaveFilingsContext(ctx, Parse(ctx, parseArgs, checkpoints), saver); err != nil {
+		log.Fatalln("Saving stopped:", err) // Exit status for the scheduler of the ingestion
+	}
 
 }
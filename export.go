@@ -0,0 +1,190 @@
+//Exchange formats: the network is exported to (and imported from) the files of the other graph tools.
+//The kinds, weights and validity of the nodes and edges are exported along with the attributes of their data,
+//which is imported back as Attributes.
+package go_nets
+
+import (
+	"io"
+	"log"
+	"os"
+	"sort"
+)
+
+func (n *Network) sortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(n.Nodes))
+	for _, node := range n.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+func (n *Network) sortedEdges() []*Edge {
+	edges := make([]*Edge, 0, len(n.Edges))
+	for _, edge := range n.Edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Name < edges[j].Name })
+	return edges
+}
+
+func exportFile(fp string, write func(io.Writer) error) error {
+	fi, err := os.Create(fp)
+	if err != nil {
+		return err
+	}
+	if err = write(fi); err != nil {
+		fi.Close()
+		return err
+	}
+	return fi.Close()
+}
+
+func importFile(fp string, read func(io.Reader) error) error {
+	fi, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	return read(fi)
+}
+
+//Attributes --
+
+type exportedAttribute struct {
+	Name string
+	Kind AttrKind
+}
+
+//exportedAttributes lists the attributes of the schema, sorted by name. The mixed ones are exported as strings,
+//and the ones named after a property of the nodes or edges (the reserved names) are skipped.
+func exportedAttributes(s Schema, reserved ...string) []exportedAttribute {
+	attributes := []exportedAttribute{}
+	for name, kind := range s {
+		if isReserved(name, reserved) {
+			log.Printf("EXPORT WARNING: attribute %q not exported, its name is reserved", name)
+			continue
+		}
+		if kind == MixedAttr {
+			kind = StringAttr
+		}
+		attributes = append(attributes, exportedAttribute{name, kind})
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Name < attributes[j].Name })
+	return attributes
+}
+
+func isReserved(name string, reserved []string) bool {
+	for _, r := range reserved {
+		if name == r {
+			return true
+		}
+	}
+	return false
+}
+
+//exportedValue formats the attribute as it is persisted. False when the attribute is missing.
+func exportedValue(data AttrGetter, key string) (string, bool) {
+	if data == nil || data.GetAttribute(key) == nil {
+		return "", false
+	}
+	_, value, err := formatAttribute(data.GetAttribute(key))
+	return value, err == nil
+}
+
+//xmlType is the type of the attribute in GraphML and GEXF, the times being exported as RFC 3339 strings.
+func xmlType(kind AttrKind) string {
+	switch kind {
+	case IntAttr:
+		return "long"
+	case FloatAttr:
+		return "double"
+	case BoolAttr:
+		return "boolean"
+	}
+	return "string"
+}
+
+//The times are declared in an extension attribute of their keys (gonets.type), the other tools reading them
+//as the strings of their standard type. Only the declared ones are imported as times: the values are never guessed.
+const xmlTimeType = "time"
+
+func xmlExtensionType(kind AttrKind) string {
+	if kind == TimeAttr {
+		return xmlTimeType
+	}
+	return ""
+}
+
+func xmlKind(t, extension string) AttrKind {
+	if extension == xmlTimeType {
+		return TimeAttr
+	}
+	switch t {
+	case "int", "integer", "long":
+		return IntAttr
+	case "float", "double":
+		return FloatAttr
+	case "boolean":
+		return BoolAttr
+	}
+	return StringAttr
+}
+
+//Import --
+
+//importer gathers the nodes and edges of a file before adding them to the network.
+type importer struct {
+	format   string
+	directed bool
+	nodes    []*SimpleNoder
+	edges    []*SimpleEdger
+}
+
+func newImporter(format string) *importer {
+	return &importer{format: format, directed: true}
+}
+
+//setAttribute parses the value of an attribute declared with the given kind.
+func (im *importer) setAttribute(data *Attributes, owner, key string, kind AttrKind, value string) {
+	v, err := parseAttribute(kind, value)
+	if err != nil {
+		log.Printf("%s_IMPORT WARNING: attribute %q of %q skipped: %s", im.format, key, owner, err)
+		return
+	}
+	if *data == nil {
+		*data = Attributes{}
+	}
+	(*data)[key] = v
+}
+
+func (im *importer) nodeKind(owner, s string) NodeKind {
+	nk, ok := ParseNodeKind(s)
+	if !ok {
+		log.Printf("%s_IMPORT WARNING: unknown kind %q of node %q", im.format, s, owner)
+	}
+	return nk
+}
+
+func (im *importer) edgeKind(owner, s string) EdgeKind {
+	ek, ok := ParseEdgeKind(s)
+	if !ok {
+		log.Printf("%s_IMPORT WARNING: unknown kind %q of edge %q", im.format, s, owner)
+	}
+	return ek
+}
+
+//addTo adds the nodes and then the edges to the network, which takes the direction of the file when it has no edges yet.
+func (im *importer) addTo(n *Network) {
+	if n.Nedges == 0 {
+		n.Symmetrical = !im.directed
+	} else if n.Symmetrical == im.directed {
+		log.Printf("%s_IMPORT WARNING: the direction of the file differs from the one of network %q (symmetrical: %t)", im.format, n.Name, n.Symmetrical)
+	}
+	for _, sn := range im.nodes {
+		n.AddNode(sn)
+	}
+	for _, se := range im.edges {
+		n.AddEdge(se)
+	}
+}
@@ -0,0 +1,118 @@
+package go_nets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExportNetwork() Network {
+	network := newTestNetwork("TestExport", [][3]string{{"a", "b", "ER"}, {"b", "c", "EE"}, {"c", "a", "RR"}, {"a", "b", "ER"}})
+	network.Aggregator = SumWeights
+	network.Nodes["a"].SetAttribute("flagged", true)
+	network.Nodes["b"].SetAttribute("rank", 2)
+	network.Edges["b_c"].ValidFrom = time.Date(2014, 2, 15, 0, 0, 0, 0, time.UTC)
+	network.Edges["b_c"].SetAttribute("note", `<it's "quoted">`)
+	f := newTestFiling(137363375543, []string{"EMPLOYMENT DEVELOPMENT DEPARTMENT"}, []string{"john.doe", "jane.doe"})
+	network.AddDispatcher(&f)
+	return network
+}
+
+//checkImport compares the imported network to the exported one.
+func checkImport(t *testing.T, network, imported *Network) {
+	if imported.Nnodes != network.Nnodes || imported.Nedges != network.Nedges {
+		t.Fatalf("Got %d nodes and %d edges, expected %d and %d", imported.Nnodes, imported.Nedges, network.Nnodes, network.Nedges)
+	}
+	for name, node := range network.Nodes {
+		node2 := imported.Nodes[name]
+		if node2.Kind != node.Kind {
+			t.Errorf("Kind of node %q changed from %s to %s", name, node.Kind, node2.Kind)
+		}
+		for _, k := range node.NodeData.AttributeKeys() {
+			if !attributeMatches(node2.NodeData, k, node.GetAttribute(k)) {
+				t.Errorf("Attribute %q of node %q changed from %#v to %#v", k, name, node.GetAttribute(k), node2.GetAttribute(k))
+			}
+		}
+	}
+	for name, edge := range network.Edges {
+		edge2 := imported.Edges[name]
+		if edge2.Kind != edge.Kind || edge2.Weight != edge.Weight || edge2.Src.Name != edge.Src.Name || !edge2.ValidFrom.Equal(edge.ValidFrom) {
+			t.Errorf("Edge %q changed from %v to %v", name, edge, edge2)
+		}
+		for _, k := range edge.LinkData.AttributeKeys() {
+			if v := edge.GetAttribute(k); v != nil && !attributeMatches(edge2.LinkData, k, v) {
+				t.Errorf("Attribute %q of edge %q changed from %#v to %#v", k, name, v, edge2.GetAttribute(k))
+			}
+		}
+	}
+}
+
+func testExchange(t *testing.T, write func(*Network, io.Writer) error, read func(*Network, io.Reader) error) {
+	network := newExportNetwork()
+	var b bytes.Buffer
+	if err := write(&network, &b); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(b.String()[:400])
+	imported := NewNetwork("TestImport", ioutil.Discard, testFolder)
+	if err := read(&imported, &b); err != nil {
+		t.Fatal(err)
+	}
+	checkImport(t, &network, &imported)
+	// Directed networks keep their direction
+	network.Symmetrical = false
+	b.Reset()
+	write(&network, &b)
+	imported = NewNetwork("TestImport", ioutil.Discard, testFolder)
+	if err := read(&imported, &b); err != nil || imported.Symmetrical {
+		t.Errorf("Got a symmetrical network %t (%v), expected a directed one", imported.Symmetrical, err)
+	}
+}
+
+func TestGraphML(t *testing.T) {
+	fmt.Println("### TESTING the GraphML export and import")
+	testExchange(t, (*Network).WriteGraphML, (*Network).ReadGraphML)
+	// A graph edited in yEd: graphics keys, and edges without id
+	yed := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:y="http://www.yworks.com/xml/graphml">
+  <key id="d0" for="node" attr.name="kind" attr.type="string"/>
+  <key id="d1" for="node" yfiles.type="nodegraphics"/>
+  <key id="d2" for="edge" attr.name="weight" attr.type="double"/>
+  <key id="d3" for="all" attr.name="since" attr.type="int"/>
+  <key id="d4" for="node" attr.name="label" attr.type="string"/>
+  <graph id="G" edgedefault="directed">
+    <node id="x"><data key="d0">Receiver</data><data key="d1"><y:ShapeNode/></data><data key="d3">1984</data><data key="d4">2014-02-15T00:00:00Z</data></node>
+    <node id="y"/>
+    <edge source="x" target="y"><data key="d2">2.5</data></edge>
+  </graph>
+</graphml>`
+	network := NewNetwork("TestYEd", ioutil.Discard, testFolder)
+	if err := network.ReadGraphML(strings.NewReader(yed)); err != nil {
+		t.Fatal(err)
+	}
+	if network.Nodes["x"].Kind != Receiver || network.Nodes["x"].GetAttribute("since") != 1984 || network.Edges["x_y"] == nil || network.Edges["x_y"].Weight != 2.5 {
+		t.Errorf("Unexpected import of the yEd graph: %v %v", network.Nodes, network.Edges)
+	}
+	if label, ok := network.Nodes["x"].GetAttribute("label").(string); !ok || label != "2014-02-15T00:00:00Z" {
+		t.Errorf("A string attribute that looks like a time should stay a string, got %#v", network.Nodes["x"].GetAttribute("label"))
+	}
+}
+
+func TestGEXF(t *testing.T) {
+	fmt.Println("### TESTING the GEXF export and import")
+	testExchange(t, (*Network).WriteGEXF, (*Network).ReadGEXF)
+	// Through a file
+	network := newExportNetwork()
+	if err := network.ExportGEXF(testFolder + "TestGEXF.gexf"); err != nil {
+		t.Fatal(err)
+	}
+	imported := NewNetwork("TestGEXF", ioutil.Discard, testFolder)
+	if err := imported.ImportGEXF(testFolder + "TestGEXF.gexf"); err != nil {
+		t.Fatal(err)
+	}
+	checkImport(t, &network, &imported)
+}
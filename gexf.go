@@ -0,0 +1,206 @@
+package go_nets
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+//GEXF, as read by Gephi --
+//The weights of the edges are native, the kinds of the nodes and edges and the validity of the edges
+//are attributes of their own, next to the attributes of the data. The files of GEXF 1.2 and 1.3 are imported.
+
+const gexfNamespace = "http://www.gexf.net/1.2draft"
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr,omitempty"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	Mode            string           `xml:"mode,attr,omitempty"`
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+	Ext   string `xml:"gonets.type,attr,omitempty"` // See xmlTimeType
+}
+
+type gexfNode struct {
+	ID     string      `xml:"id,attr"`
+	Label  string      `xml:"label,attr,omitempty"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string      `xml:"id,attr,omitempty"`
+	Source string      `xml:"source,attr"`
+	Target string      `xml:"target,attr"`
+	Weight string      `xml:"weight,attr,omitempty"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+//Titles of the attributes of the node and edge properties
+var (
+	gexfNodeAttributes = []string{"kind"}
+	gexfEdgeAttributes = []string{"kind", "weight", "valid_from", "valid_to"}
+)
+
+//WriteGEXF writes the network as a GEXF 1.2 document.
+func (n *Network) WriteGEXF(w io.Writer) error {
+	doc := gexf{Xmlns: gexfNamespace, Version: "1.2"}
+	doc.Graph = gexfGraph{Mode: "static", DefaultEdgeType: "directed"}
+	if n.Symmetrical {
+		doc.Graph.DefaultEdgeType = "undirected"
+	}
+	nodeAttributes := exportedAttributes(n.NodeSchema(), gexfNodeAttributes...)
+	nodeClass := gexfAttributes{"node", []gexfAttribute{{"kind", "kind", "string", ""}}}
+	for _, a := range nodeAttributes {
+		nodeClass.Attributes = append(nodeClass.Attributes, gexfAttribute{"n_" + a.Name, a.Name, xmlType(a.Kind), xmlExtensionType(a.Kind)})
+	}
+	edgeAttributes := exportedAttributes(n.EdgeSchema(), gexfEdgeAttributes...)
+	edgeClass := gexfAttributes{"edge", []gexfAttribute{
+		{"kind", "kind", "string", ""},
+		{"valid_from", "valid_from", "string", xmlTimeType},
+		{"valid_to", "valid_to", "string", xmlTimeType},
+	}}
+	for _, a := range edgeAttributes {
+		edgeClass.Attributes = append(edgeClass.Attributes, gexfAttribute{"e_" + a.Name, a.Name, xmlType(a.Kind), xmlExtensionType(a.Kind)})
+	}
+	doc.Graph.Attributes = []gexfAttributes{nodeClass, edgeClass}
+	for _, node := range n.sortedNodes() {
+		gn := gexfNode{node.Name, node.Name, []gexfValue{{"kind", node.Kind.String()}}}
+		for _, a := range nodeAttributes {
+			if value, ok := exportedValue(node.NodeData, a.Name); ok {
+				gn.Values = append(gn.Values, gexfValue{"n_" + a.Name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+	for _, edge := range n.sortedEdges() {
+		ge := gexfEdge{edge.Name, edge.Src.Name, edge.Dst.Name, strconv.FormatFloat(float64(edge.Weight), 'g', -1, 32),
+			[]gexfValue{{"kind", edge.Kind.String()}}}
+		if !edge.ValidFrom.IsZero() {
+			ge.Values = append(ge.Values, gexfValue{"valid_from", formatTime(edge.ValidFrom)})
+		}
+		if !edge.ValidTo.IsZero() {
+			ge.Values = append(ge.Values, gexfValue{"valid_to", formatTime(edge.ValidTo)})
+		}
+		for _, a := range edgeAttributes {
+			if value, ok := exportedValue(edge.LinkData, a.Name); ok {
+				ge.Values = append(ge.Values, gexfValue{"e_" + a.Name, value})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ge)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+//ReadGEXF adds the nodes and edges of a GEXF document to the network. The edges without id are named
+//"source_target". The network takes the direction of the graph when it has no edges yet.
+func (n *Network) ReadGEXF(r io.Reader) error {
+	var doc gexf
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("GEXF ERROR: %v", err)
+	}
+	attributes := map[string]map[string]gexfAttribute{"node": {}, "edge": {}}
+	for _, class := range doc.Graph.Attributes {
+		if attributes[class.Class] == nil {
+			continue
+		}
+		for _, a := range class.Attributes {
+			attributes[class.Class][a.ID] = a
+		}
+	}
+	im := newImporter("GEXF")
+	im.directed = doc.Graph.DefaultEdgeType != "undirected"
+	for _, gn := range doc.Graph.Nodes {
+		sn := &SimpleNoder{Name: gn.ID}
+		for _, v := range gn.Values {
+			a, ok := attributes["node"][v.For]
+			if !ok {
+				continue
+			}
+			if a.Title == "kind" {
+				sn.Kind = im.nodeKind(sn.Name, v.Value)
+			} else {
+				im.setAttribute(&sn.Data, sn.Name, a.Title, xmlKind(a.Type, a.Ext), v.Value)
+			}
+		}
+		im.nodes = append(im.nodes, sn)
+	}
+	for _, ge := range doc.Graph.Edges {
+		se := &SimpleEdger{Name: ge.ID, SrcId: ge.Source, DstId: ge.Target}
+		if se.Name == "" {
+			se.Name = ge.Source + "_" + ge.Target
+		}
+		if ge.Weight != "" {
+			w, err := strconv.ParseFloat(ge.Weight, 32)
+			if err != nil {
+				return fmt.Errorf("GEXF ERROR: weight of edge %q: %v", se.Name, err)
+			}
+			se.Weight, se.HasWeight = float32(w), true
+		}
+		for _, v := range ge.Values {
+			a, ok := attributes["edge"][v.For]
+			if !ok {
+				continue
+			}
+			var err error
+			switch a.Title {
+			case "kind":
+				se.Kind = im.edgeKind(se.Name, v.Value)
+			case "valid_from":
+				se.ValidFrom, err = parseTime(v.Value)
+			case "valid_to":
+				se.ValidTo, err = parseTime(v.Value)
+			default:
+				im.setAttribute(&se.Data, se.Name, a.Title, xmlKind(a.Type, a.Ext), v.Value)
+			}
+			if err != nil {
+				return fmt.Errorf("GEXF ERROR: %s of edge %q: %v", a.Title, se.Name, err)
+			}
+		}
+		im.edges = append(im.edges, se)
+	}
+	im.addTo(n)
+	return nil
+}
+
+//ExportGEXF writes the network to a GEXF file.
+func (n *Network) ExportGEXF(fp string) error {
+	return exportFile(fp, n.WriteGEXF)
+}
+
+//ImportGEXF adds the nodes and edges of a GEXF file to the network.
+func (n *Network) ImportGEXF(fp string) error {
+	return importFile(fp, n.ReadGEXF)
+}
@@ -0,0 +1,191 @@
+package go_nets
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+//GraphML, as read by yEd and Gephi --
+//The kinds of the nodes and edges, the weights and the validity of the edges are keys of their own,
+//next to the keys of the attributes. The keys without attr.name (e.g. the graphics of yEd) are ignored on import.
+
+type graphML struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr,omitempty"`
+	Type string `xml:"attr.type,attr,omitempty"`
+	Ext  string `xml:"gonets.type,attr,omitempty"` // See xmlTimeType
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr,omitempty"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+//Names of the keys of the node and edge properties
+var (
+	graphMLNodeKeys = []string{"kind"}
+	graphMLEdgeKeys = []string{"kind", "weight", "valid_from", "valid_to"}
+)
+
+//WriteGraphML writes the network as a GraphML document.
+func (n *Network) WriteGraphML(w io.Writer) error {
+	doc := graphML{Keys: []graphMLKey{
+		{"kind", "node", "kind", "string", ""},
+		{"edge_kind", "edge", "kind", "string", ""},
+		{"weight", "edge", "weight", "double", ""},
+		{"valid_from", "edge", "valid_from", "string", xmlTimeType},
+		{"valid_to", "edge", "valid_to", "string", xmlTimeType},
+	}}
+	nodeAttributes := exportedAttributes(n.NodeSchema(), graphMLNodeKeys...)
+	for _, a := range nodeAttributes {
+		doc.Keys = append(doc.Keys, graphMLKey{"n_" + a.Name, "node", a.Name, xmlType(a.Kind), xmlExtensionType(a.Kind)})
+	}
+	edgeAttributes := exportedAttributes(n.EdgeSchema(), graphMLEdgeKeys...)
+	for _, a := range edgeAttributes {
+		doc.Keys = append(doc.Keys, graphMLKey{"e_" + a.Name, "edge", a.Name, xmlType(a.Kind), xmlExtensionType(a.Kind)})
+	}
+	doc.Graph = graphMLGraph{ID: n.Name, EdgeDefault: "directed"}
+	if n.Symmetrical {
+		doc.Graph.EdgeDefault = "undirected"
+	}
+	for _, node := range n.sortedNodes() {
+		gn := graphMLNode{node.Name, []graphMLData{{"kind", node.Kind.String()}}}
+		for _, a := range nodeAttributes {
+			if value, ok := exportedValue(node.NodeData, a.Name); ok {
+				gn.Data = append(gn.Data, graphMLData{"n_" + a.Name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+	for _, edge := range n.sortedEdges() {
+		ge := graphMLEdge{edge.Name, edge.Src.Name, edge.Dst.Name, []graphMLData{
+			{"edge_kind", edge.Kind.String()},
+			{"weight", strconv.FormatFloat(float64(edge.Weight), 'g', -1, 32)},
+		}}
+		if !edge.ValidFrom.IsZero() {
+			ge.Data = append(ge.Data, graphMLData{"valid_from", formatTime(edge.ValidFrom)})
+		}
+		if !edge.ValidTo.IsZero() {
+			ge.Data = append(ge.Data, graphMLData{"valid_to", formatTime(edge.ValidTo)})
+		}
+		for _, a := range edgeAttributes {
+			if value, ok := exportedValue(edge.LinkData, a.Name); ok {
+				ge.Data = append(ge.Data, graphMLData{"e_" + a.Name, value})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ge)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+//ReadGraphML adds the nodes and edges of a GraphML document to the network. The edges without id are named
+//"source_target". The network takes the direction of the graph when it has no edges yet.
+func (n *Network) ReadGraphML(r io.Reader) error {
+	var doc graphML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("GRAPHML ERROR: %v", err)
+	}
+	keys := map[string]graphMLKey{}
+	for _, key := range doc.Keys {
+		if key.Name != "" {
+			keys[key.ID] = key
+		}
+	}
+	im := newImporter("GRAPHML")
+	im.directed = doc.Graph.EdgeDefault != "undirected"
+	for _, gn := range doc.Graph.Nodes {
+		sn := &SimpleNoder{Name: gn.ID}
+		for _, d := range gn.Data {
+			key, ok := keys[d.Key]
+			if !ok || key.For != "node" && key.For != "all" {
+				continue
+			}
+			if key.Name == "kind" {
+				sn.Kind = im.nodeKind(sn.Name, d.Value)
+			} else {
+				im.setAttribute(&sn.Data, sn.Name, key.Name, xmlKind(key.Type, key.Ext), d.Value)
+			}
+		}
+		im.nodes = append(im.nodes, sn)
+	}
+	for _, ge := range doc.Graph.Edges {
+		se := &SimpleEdger{Name: ge.ID, SrcId: ge.Source, DstId: ge.Target}
+		if se.Name == "" {
+			se.Name = ge.Source + "_" + ge.Target
+		}
+		for _, d := range ge.Data {
+			key, ok := keys[d.Key]
+			if !ok || key.For != "edge" && key.For != "all" {
+				continue
+			}
+			var err error
+			switch key.Name {
+			case "kind":
+				se.Kind = im.edgeKind(se.Name, d.Value)
+			case "weight":
+				var w float64
+				w, err = strconv.ParseFloat(d.Value, 32)
+				se.Weight, se.HasWeight = float32(w), true
+			case "valid_from":
+				se.ValidFrom, err = parseTime(d.Value)
+			case "valid_to":
+				se.ValidTo, err = parseTime(d.Value)
+			default:
+				im.setAttribute(&se.Data, se.Name, key.Name, xmlKind(key.Type, key.Ext), d.Value)
+			}
+			if err != nil {
+				return fmt.Errorf("GRAPHML ERROR: %s of edge %q: %v", key.Name, se.Name, err)
+			}
+		}
+		im.edges = append(im.edges, se)
+	}
+	im.addTo(n)
+	return nil
+}
+
+//ExportGraphML writes the network to a GraphML file.
+func (n *Network) ExportGraphML(fp string) error {
+	return exportFile(fp, n.WriteGraphML)
+}
+
+//ImportGraphML adds the nodes and edges of a GraphML file to the network.
+func (n *Network) ImportGraphML(fp string) error {
+	return importFile(fp, n.ReadGraphML)
+}
@@ -0,0 +1,132 @@
+package go_nets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"code.google.com/p/go.text/encoding"
+)
+
+//JSONParser parses files of filings in JSON Lines: one FileDetail object per line, with the same
+//field names as the XML elements. The attributes (e.g. TransType) can be given either as a plain
+//string or as an object ({"Type": "Initial"}). A malformed line only loses this record.
+type JSONParser struct {
+	FileDir  string
+	FileName string
+	Encoding encoding.Encoding
+	OnError  func(error) // Called for each failed record (can be nil)
+	Skip     int         // Number of records to skip, already saved by a previous run
+}
+
+func (p *JSONParser) Parse(c chan Filing, logDst io.Writer) (ParseReport, error) {
+	return p.ParseContext(context.Background(), c, logDst)
+}
+
+func (p *JSONParser) ParseContext(ctx context.Context, c chan Filing, logDst io.Writer) (report ParseReport, err error) {
+	defer close(c)
+	report.FileName = p.FileName
+
+	// Unpack arguments & Initialize
+	// Logger
+	logger := newParserLogger(logDst)
+
+	// Open input file (transforming the encoding of the reading pipe) and defer closing
+	fi, fiUTF8, err := openEncoded(p.FileDir, p.FileName, p.Encoding)
+	if err != nil {
+		logger.Println("PARSE_ERROR:", err)
+		return report, err
+	}
+	logger.Println("Opening file: ", p.FileName)
+	defer func() {
+		if errOs := fi.Close(); errOs != nil && err == nil {
+			err = errOs
+		}
+	}()
+	// Parse the lines
+	reader := bufio.NewReader(fiUTF8)
+	i := 0
+	line := 0
+	t0 := time.Now()
+	for {
+		if err = ctx.Err(); err != nil {
+			logger.Println("PARSE_INTERRUPTED:", err)
+			return report, err
+		}
+		b, errRead := reader.ReadBytes('\n')
+		line++
+		if b = bytes.TrimSpace(b); len(b) > 0 {
+			i++
+			if i <= p.Skip {
+				report.Skipped++
+			} else if err = p.parseLine(ctx, c, b, &report, &RecordError{p.FileName, i, int64(line), nil}, logger); err != nil {
+				logger.Println("PARSE_INTERRUPTED:", err)
+				return report, err
+			}
+		}
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			fmt.Println("Error for file " + p.FileName + "...")
+			logger.Println("PARSE_ERROR:", errRead)
+			return report, errRead
+		}
+	}
+	t1 := time.Now()
+	fmt.Printf("\n Successfully parsed %d filings in %v from file %s (%d discarded, %d failed). \n", i, t1.Sub(t0), p.FileName, report.Discarded, report.Failed)
+	return report, nil
+}
+
+//parseLine only fails when the context is cancelled.
+func (p *JSONParser) parseLine(ctx context.Context, c chan Filing, b []byte, report *ParseReport, errRecord *RecordError, logger *log.Logger) error {
+	var f Filing
+	if errRecord.Err = json.Unmarshal(b, &f); errRecord.Err != nil {
+		report.fail(errRecord, logger, p.OnError)
+		return nil
+	}
+	f.SetSource(p.FileName, errRecord.Record)
+	sent, err := sendFiling(ctx, c, f, logger)
+	if sent {
+		report.Parsed++
+	} else if err == nil {
+		report.Discarded++
+	}
+	return err
+}
+
+//The attribute containers accept a plain string, or an object holding the attribute.
+func unmarshalAttr(data []byte, key string) (string, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return s, nil
+	}
+	m := map[string]string{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", err
+	}
+	if v, ok := m[key]; ok {
+		return v, nil
+	}
+	return m["Attr"], nil
+}
+
+func (a *AttrMethodContainer) UnmarshalJSON(data []byte) (err error) {
+	a.Attr, err = unmarshalAttr(data, "Method")
+	return err
+}
+
+func (a *AttrTypeContainer) UnmarshalJSON(data []byte) (err error) {
+	a.Attr, err = unmarshalAttr(data, "Type")
+	return err
+}
+
+func (a *AttrActionContainer) UnmarshalJSON(data []byte) (err error) {
+	a.Attr, err = unmarshalAttr(data, "Action")
+	return err
+}
@@ -0,0 +1,104 @@
+package go_nets
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const testFileDetail = `<FileDetail>
+  <TransType Type="Amendment"/>
+  <FilingMethod Method="Paper"/>
+  <AmendmentType Type="Assignment"/>
+  <AmendmentActionLoop>
+    <AmendmentAction Action="SecuredPartyAdd"/>
+  </AmendmentActionLoop>
+  <OriginalFileNumber>137363375543</OriginalFileNumber>
+  <OriginalFileDate>20130522 1700</OriginalFileDate>
+  <LapseDate>20230522</LapseDate>
+  <FileNumber>137363375544</FileNumber>
+  <FileDate>20130601 0900</FileDate>
+  <AltFilingType Type="StateLien"/>
+  <Debtors>
+    <DebtorName>
+      <Names>
+        <OrganizationName>TRUE MASSAGE &amp; WELLNESS</OrganizationName>
+        <MailAddress>760 MARKET ST STE 817</MailAddress>
+        <City>San Francisco</City>
+        <State>CA</State>
+        <PostalCode>94102</PostalCode>
+        <Country>USA</Country>
+      </Names>
+    </DebtorName>
+  </Debtors>
+  <Secured>
+    <Names>
+      <OrganizationName>EMPLOYMENT DEVELOPMENT DEPARTMENT</OrganizationName>
+      <MailAddress>PO BOX 826880</MailAddress>
+      <City>Sacramento</City>
+      <State>CA</State>
+      <PostalCode>94280</PostalCode>
+      <Country>US</Country>
+    </Names>
+  </Secured>
+</FileDetail>`
+
+const testFileDetailJSON = `{"TransType": {"Type": "Amendment"}, "FilingMethod": "Paper", "AmendmentType": "Assignment",` +
+	` "AmendmentActionLoop": [{"Action": "SecuredPartyAdd"}], "OriginalFileNumber": 137363375543,` +
+	` "OriginalFileDate": "20130522 1700", "LapseDate": "20230522", "FileNumber": 137363375544, "FileDate": "20130601 0900",` +
+	` "AltFilingType": "StateLien",` +
+	` "Debtors": [{"OrganizationName": "TRUE MASSAGE & WELLNESS", "MailAddress": "760 MARKET ST STE 817", "City": "San Francisco", "State": "CA", "PostalCode": "94102", "Country": "USA"}],` +
+	` "Secured": [{"OrganizationName": "EMPLOYMENT DEVELOPMENT DEPARTMENT", "MailAddress": "PO BOX 826880", "City": "Sacramento", "State": "CA", "PostalCode": "94280", "Country": "US"}]}`
+
+func TestJSONParser(t *testing.T) {
+	fmt.Println("### TESTING the JSON Lines parser")
+	lines := testFileDetailJSON + "\n" +
+		"\n" + // Blank lines are ignored
+		`{"FileNumber": 12, "Debtors": [` + "\n" + // Malformed, skipped
+		`{"FileNumber": 13, "Debtors": [{"OrganizationName": "ALONE"}]}` + "\n" + // Discarded, only one party
+		testFileDetailJSON // No final new line
+	os.Mkdir(testFolder, os.FileMode(0777))
+	if err := ioutil.WriteFile(testFolder+"TestJSONParser.jsonl", []byte(lines), 0666); err != nil {
+		t.Fatal(err)
+	}
+	parser := NewFilingParser(testFolder, "TestJSONParser.jsonl", nil)
+	if _, ok := parser.(*JSONParser); !ok {
+		t.Fatalf("Got a %T for a .jsonl file", parser)
+	}
+	cs := make(chan Filing)
+	filings := []Filing{}
+	done := make(chan bool)
+	go func() {
+		for f := range cs {
+			filings = append(filings, f)
+		}
+		done <- true
+	}()
+	report, err := parser.Parse(cs, ioutil.Discard)
+	<-done
+	if err != nil || report.Parsed != 2 || report.Discarded != 1 || report.Failed != 1 {
+		t.Errorf("Got %s (%v), expected 2 parsed, 1 discarded, 1 failed", report, err)
+	}
+	if re, ok := report.Errors[0].(*RecordError); !ok || re.Offset != 3 {
+		t.Errorf("The malformed line is the third one, got %v", report.Errors[0])
+	}
+	if len(filings) != 2 {
+		t.Fatalf("Got %d filings, expected 2", len(filings))
+	}
+	// Same filing as the XML one
+	var expected Filing
+	if err := xml.Unmarshal([]byte(testFileDetail), &expected); err != nil {
+		t.Fatal(err)
+	}
+	expected.XMLName = xml.Name{}
+	expected.SetSource("TestJSONParser.jsonl", 1)
+	if !reflect.DeepEqual(filings[0], expected) {
+		t.Errorf("The JSON filing differs from the XML one:\n%+v\n%+v", filings[0], expected)
+	}
+	if !filings[0].IsAmendment() || !filings[0].Has("SecuredPartyAdd") {
+		t.Error("The amendment attributes have been lost")
+	}
+}
@@ -1,6 +1,7 @@
 package go_nets
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"regexp"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/gonum/floats"
 	"github.com/gonum/matrix/mat64"
@@ -30,22 +32,36 @@ type NodeKind int
 const (
 	Emitter NodeKind = iota
 	Receiver
+	Hub // Node standing for a record (e.g. a filing) that connects agents, see Network.Project
 )
 
 func (nk NodeKind) String() string {
 	NKStrings := []string{
 		"Emitter",
 		"Receiver",
+		"Hub",
 	}
 	return NKStrings[int(nk)]
 }
 
+//ParseNodeKind returns the NodeKind named s, as printed by String or by its initial (E, R or H).
+func ParseNodeKind(s string) (NodeKind, bool) {
+	for nk := Emitter; nk <= Hub; nk++ {
+		if nk.String() == s || nk.String()[:1] == s {
+			return nk, true
+		}
+	}
+	return Emitter, false
+}
+
 type EdgeKind int
 
 const (
 	ER EdgeKind = iota
 	EE
 	RR
+	EH // Emitter to Hub
+	HR // Hub to Receiver
 )
 
 func (ek EdgeKind) String() string {
@@ -53,10 +69,23 @@ func (ek EdgeKind) String() string {
 		"Emitter-Receiver",
 		"Emitter-Emitter",
 		"Receiver-Receiver",
+		"Emitter-Hub",
+		"Hub-Receiver",
 	}
 	return EKStrings[int(ek)]
 }
 
+//ParseEdgeKind returns the EdgeKind named s, as printed by String or by its short name (ER, EE, RR, EH or HR).
+func ParseEdgeKind(s string) (EdgeKind, bool) {
+	EKShortStrings := []string{"ER", "EE", "RR", "EH", "HR"}
+	for ek := ER; ek <= HR; ek++ {
+		if ek.String() == s || EKShortStrings[int(ek)] == s {
+			return ek, true
+		}
+	}
+	return ER, false
+}
+
 type EdgeToNode struct {
 	*Edge
 	ToNode *Node
@@ -65,10 +94,19 @@ type EdgeToNode struct {
 type Node struct {
 	Name     string
 	Kind     NodeKind
-	Edges    []*EdgeToNode //map[string]*Edge
+	Edges    []*EdgeToNode //map[string]*Edge. Out-edges when the network is directed, all edges otherwise.
+	InEdges  []*EdgeToNode // In-edges, ToNode being the source of the edge. Same as Edges when the network is symmetrical.
 	NodeData AttrGetter
 }
 
+func (n *Node) OutDegree() int {
+	return len(n.Edges)
+}
+
+func (n *Node) InDegree() int {
+	return len(n.InEdges)
+}
+
 // //OLD CODE, inefficient. Created the EdgeToNode to Change that
 // func (n *Node) getNeighbor(e *Edge) *Node {
 // 	if e.Src == n {
@@ -80,15 +118,23 @@ type Node struct {
 // //--------------------------------------------------------------
 
 type Edge struct {
-	Name     string
-	Kind     EdgeKind
-	Src      *Node
-	Dst      *Node
-	LinkData *AttrGetter
+	Name      string
+	Kind      EdgeKind
+	Src       *Node
+	Dst       *Node
+	Weight    float32
+	ValidFrom time.Time // Zero when the edge has always been valid
+	ValidTo   time.Time // Zero when the edge is still valid (excluded from the validity otherwise)
+	LinkData  AttrGetter
 }
 
-type AttrGetter interface {
-	// GetAttribute(string) interface{}
+//Strength is the summed weight of the edges of the node (its degree when all weights are 1)
+func (n *Node) Strength() float32 {
+	s := float32(0)
+	for _, e := range n.Edges {
+		s = s + e.Weight
+	}
+	return s
 }
 
 type Network struct {
@@ -100,14 +146,17 @@ type Network struct {
 	Nnodes int
 	// EdgeNames []string //AL Needed to iterate over all edges quickly... ?
 	// NodeNames []string //AL Needed to iterate over all nodes quickly... ?
-	//LinksData []AttrGetter //AL to be fixed / looked into.
 	// Parameters of the Network
-	Symmetrical bool // TODO let it be assymetrical in the nodes and co.
+	Symmetrical bool               // When false, the network is directed (from Src to Dst). To be set before adding any edge.
+	Aggregator  WeightAggregator   // Combines the weights when an edge is added again. nil drops the repeated edge.
+	EdgeKey     func(Edger) string // Key under which an edge is stored. nil means the identifier of the Edger.
 	// Meta parameters
 	Folder         string
 	Logger         *log.Logger
 	PersistingFile string
 	DBDriver       string
+	// Index of the edges by the original filing they come from, for the amendments (see Filing.Amend)
+	originals map[int][]*Edge
 }
 
 func NewNetwork(name string, logWriter io.Writer, folder string) Network {
@@ -140,10 +189,13 @@ func NewNetwork(name string, logWriter io.Writer, folder string) Network {
 		make(map[string]*Node),
 		0,
 		true,
+		CountWeights,
+		nil,
 		folder,
 		log.New(logWriter, "Network: ", log.Lshortfile),
 		pf,
 		"sqlite3",
+		nil,
 	}
 }
 
@@ -157,6 +209,7 @@ type Noder interface {
 type SimpleNoder struct {
 	Name string
 	Kind NodeKind
+	Data Attributes
 }
 
 func (s *SimpleNoder) GetIdentifier() string {
@@ -166,10 +219,17 @@ func (s *SimpleNoder) GetKind() NodeKind {
 	return s.Kind
 }
 func (s *SimpleNoder) GetData() AttrGetter {
-	return 0
+	return s.Data
 }
-func (s *SimpleNoder) UpdateData(AttrGetter) AttrGetter {
-	return 0
+func (s *SimpleNoder) UpdateData(data AttrGetter) AttrGetter { // The latest attributes win
+	if len(s.Data) == 0 {
+		return data
+	}
+	merged := NewAttributes(data)
+	for k, v := range s.Data {
+		merged[k] = v
+	}
+	return merged
 }
 
 type Edger interface {
@@ -178,12 +238,17 @@ type Edger interface {
 	GetData() AttrGetter
 	GetSrcId() string
 	GetDstId() string
+	GetWeight() float32
 }
 
 type SimpleEdger struct {
-	Name         string
-	Kind         EdgeKind
-	SrcId, DstId string
+	Name               string
+	Kind               EdgeKind
+	SrcId, DstId       string
+	Weight             float32 // DefaultWeight when left to 0, unless HasWeight
+	HasWeight          bool    // The Weight is given, even when it is 0
+	ValidFrom, ValidTo time.Time
+	Data               Attributes
 }
 
 func (s *SimpleEdger) GetIdentifier() string {
@@ -193,7 +258,7 @@ func (s *SimpleEdger) GetKind() EdgeKind {
 	return s.Kind
 }
 func (s *SimpleEdger) GetData() AttrGetter {
-	return 0
+	return s.Data
 }
 func (s *SimpleEdger) GetSrcId() string {
 	return s.SrcId
@@ -201,11 +266,60 @@ func (s *SimpleEdger) GetSrcId() string {
 func (s *SimpleEdger) GetDstId() string {
 	return s.DstId
 }
+func (s *SimpleEdger) GetWeight() float32 {
+	if s.Weight == 0 && !s.HasWeight {
+		return DefaultWeight
+	}
+	return s.Weight
+}
+func (s *SimpleEdger) GetValidity() (time.Time, time.Time) {
+	return s.ValidFrom, s.ValidTo
+}
+
+//Weights of the edges --
+//An edge that is added again (same key, see Network.EdgeKey) doesn't create a new edge,
+//its weight is combined with the weight of the existing one by the Aggregator of the network.
+
+const DefaultWeight float32 = 1
+
+type WeightAggregator func(current, incoming float32) float32
+
+var (
+	// Number of times the edge has been added (starting from the weight of the first one)
+	CountWeights WeightAggregator = func(current, incoming float32) float32 {
+		return current + 1
+	}
+	SumWeights WeightAggregator = func(current, incoming float32) float32 {
+		return current + incoming
+	}
+	MaxWeights WeightAggregator = func(current, incoming float32) float32 {
+		if incoming > current {
+			return incoming
+		}
+		return current
+	}
+	LatestWeight WeightAggregator = func(current, incoming float32) float32 {
+		return incoming
+	}
+)
+
+//PairKey can be used as a Network.EdgeKey to aggregate all the edges of the same kind
+//between two nodes, whatever their identifier (e.g. all the filings between a debtor and a lender).
+func PairKey(e Edger) string {
+	return e.GetSrcId() + "_" + e.GetKind().String() + "_" + e.GetDstId()
+}
 
 type Dispatcher interface {
 	Dispatch(*log.Logger) ([]Noder, []Edger)
 }
 
+//Amender is implemented by the Dispatchers whose records can update what previous records added to the network.
+//Amend returns false when the record has to be dispatched as a new one.
+type Amender interface {
+	Dispatcher
+	Amend(*Network) bool
+}
+
 //--------------
 //SECTION 1: NETWORK BUILDING
 
@@ -216,19 +330,27 @@ func (n *Network) AddNode(noder Noder) {
 			id,
 			noder.GetKind(),
 			[]*EdgeToNode{},
+			[]*EdgeToNode{},
 			noder.GetData(),
 		}
 		n.Nnodes++
 	} else { // Log & Update information
-		n.Logger.Printf("ADD_NODE WARNING: Node '%s' already present, updating information (or not!)", id)
+		n.Logger.Printf("ADD_NODE INFO: Node '%s' already present, merging its data", id)
 		node.NodeData = noder.UpdateData(node.NodeData)
 	}
 }
 
+func (n *Network) edgeKey(edger Edger) string {
+	if n.EdgeKey == nil {
+		return edger.GetIdentifier()
+	}
+	return n.EdgeKey(edger)
+}
+
 func (n *Network) AddEdge(edger Edger) {
 	srcId := edger.GetSrcId()
 	dstId := edger.GetDstId()
-	id := edger.GetIdentifier()
+	id := n.edgeKey(edger)
 	_, ok1 := n.Nodes[srcId]
 	_, ok2 := n.Nodes[dstId]
 	if !(ok1 && ok2) { // Log and return
@@ -237,23 +359,81 @@ func (n *Network) AddEdge(edger Edger) {
 		return
 	}
 	data := edger.GetData()
-	if _, ok := n.Edges[id]; !ok { // Add Edge
-		n.Edges[id] = &Edge{
+	from, to := validityOf(edger)
+	if edge, ok := n.Edges[id]; !ok { // Add Edge
+		n.connect(&Edge{
 			id,
 			edger.GetKind(),
 			n.Nodes[srcId],
 			n.Nodes[dstId],
-			&data,
-		}
-		n.Nodes[srcId].Edges = append(n.Nodes[srcId].Edges, &EdgeToNode{n.Edges[id], n.Nodes[dstId]})
-		n.Nodes[dstId].Edges = append(n.Nodes[dstId].Edges, &EdgeToNode{n.Edges[id], n.Nodes[srcId]})
-		n.Nedges++
-	} else { // Update information
+			edger.GetWeight(),
+			from,
+			to,
+			data,
+		})
+	} else if n.Aggregator == nil { // Drop it
 		n.Logger.Printf("ADD_EDGE WARNING: Edge %q (kind %s) already present, moving on...", id, edger.GetKind())
+	} else { // Update the weight and the validity
+		edge.Weight = n.Aggregator(edge.Weight, edger.GetWeight())
+		edge.widenValidity(from, to)
+	}
+}
+
+//Subfunction: register the edge in the network and in the adjacency lists of its nodes.
+func (n *Network) connect(e *Edge) {
+	n.Edges[e.Name] = e
+	out := &EdgeToNode{e, e.Dst}
+	in := &EdgeToNode{e, e.Src}
+	e.Src.Edges = append(e.Src.Edges, out)
+	e.Dst.InEdges = append(e.Dst.InEdges, in)
+	if n.Symmetrical { // The edge goes both ways
+		e.Dst.Edges = append(e.Dst.Edges, in)
+		e.Src.InEdges = append(e.Src.InEdges, out)
+	}
+	n.Nedges++
+	n.indexOriginal(e)
+}
+
+//indexOriginal registers the edge under the number of the original filing of its data, if any.
+func (n *Network) indexOriginal(e *Edge) {
+	number, ok := GetInt(e.LinkData, "original_file_number")
+	if !ok {
+		return
+	}
+	for _, indexed := range n.originals[number] {
+		if indexed == e {
+			return
+		}
+	}
+	if n.originals == nil {
+		n.originals = map[int][]*Edge{}
+	}
+	n.originals[number] = append(n.originals[number], e)
+}
+
+func (n *Network) unindexOriginal(e *Edge) {
+	number, ok := GetInt(e.LinkData, "original_file_number")
+	if !ok {
+		return
+	}
+	edges := n.originals[number]
+	for i, indexed := range edges {
+		if indexed == e {
+			edges = append(edges[:i:i], edges[i+1:]...)
+			break
+		}
+	}
+	if len(edges) == 0 {
+		delete(n.originals, number)
+	} else {
+		n.originals[number] = edges
 	}
 }
 
 func (n *Network) AddDispatcher(dispatcher Dispatcher) {
+	if amender, ok := dispatcher.(Amender); ok && amender.Amend(n) {
+		return
+	}
 	noders, edgers := dispatcher.Dispatch(n.Logger)
 	for _, noder := range noders {
 		// fmt.Println("adding node", noder.GetIdentifier()) //DEBUG
@@ -265,6 +445,98 @@ func (n *Network) AddDispatcher(dispatcher Dispatcher) {
 	}
 }
 
+//Removal of nodes and edges --
+
+//Subfunction: drop the entries of an adjacency list that go through the edge e.
+func removeEdgeToNodes(ens []*EdgeToNode, e *Edge) []*EdgeToNode {
+	i := 0
+	for _, en := range ens {
+		if en.Edge != e {
+			ens[i] = en
+			i++
+		}
+	}
+	for j := i; j < len(ens); j++ { // Let the garbage collector do its job
+		ens[j] = nil
+	}
+	return ens[:i]
+}
+
+func (n *Network) removeEdge(e *Edge) {
+	e.Src.Edges = removeEdgeToNodes(e.Src.Edges, e)
+	e.Src.InEdges = removeEdgeToNodes(e.Src.InEdges, e)
+	e.Dst.Edges = removeEdgeToNodes(e.Dst.Edges, e)
+	e.Dst.InEdges = removeEdgeToNodes(e.Dst.InEdges, e)
+	delete(n.Edges, e.Name)
+	n.Nedges--
+	n.unindexOriginal(e)
+}
+
+func (n *Network) removeNode(node *Node) {
+	incident := map[*Edge]bool{}
+	for _, en := range node.Edges {
+		incident[en.Edge] = true
+	}
+	for _, en := range node.InEdges {
+		incident[en.Edge] = true
+	}
+	for e := range incident {
+		n.removeEdge(e)
+	}
+	delete(n.Nodes, node.Name)
+	n.Nnodes--
+}
+
+//RemoveEdge removes the edge from the network and from the adjacency lists of its nodes.
+func (n *Network) RemoveEdge(id string) bool {
+	edge, ok := n.Edges[id]
+	if !ok {
+		n.Logger.Printf("REMOVE_EDGE WARNING: Edge %q not present, moving on...", id)
+		return false
+	}
+	n.removeEdge(edge)
+	return true
+}
+
+//RemoveNode removes the node and all the edges connected to it.
+func (n *Network) RemoveNode(id string) bool {
+	node, ok := n.Nodes[id]
+	if !ok {
+		n.Logger.Printf("REMOVE_NODE WARNING: Node %q not present, moving on...", id)
+		return false
+	}
+	n.removeNode(node)
+	return true
+}
+
+//RemoveEdges removes all the edges for which match returns true, and returns their number.
+func (n *Network) RemoveEdges(match func(*Edge) bool) int {
+	matchingEdges := []*Edge{}
+	for _, edge := range n.Edges {
+		if match(edge) {
+			matchingEdges = append(matchingEdges, edge)
+		}
+	}
+	for _, edge := range matchingEdges {
+		n.removeEdge(edge)
+	}
+	return len(matchingEdges)
+}
+
+//RemoveNodes removes all the nodes for which match returns true (and their edges), and returns their number.
+func (n *Network) RemoveNodes(match func(*Node) bool) int {
+	matchingNodes := []*Node{}
+	for _, node := range n.Nodes {
+		if match(node) {
+			matchingNodes = append(matchingNodes, node)
+		}
+	}
+	for _, node := range matchingNodes {
+		n.removeNode(node)
+	}
+	return len(matchingNodes)
+}
+
 func (n *Network) Summary(w io.Writer) {
 	sLog := fmt.Sprintf("## SUMMARY for Network '%s': %d Edges and %d Nodes\n", n.Name, n.Nedges, n.Nnodes)
 	if w == nil {
@@ -296,11 +568,12 @@ func compareNetworks(n1, n2 *Network) {
 	ee := 0
 	for _, edge1 := range n1.Edges {
 		if edge2, ok := n2.Edges[edge1.Name]; ok {
-			if edge1.Kind == edge2.Kind && edge1.Src.Name == edge2.Src.Name && edge1.Dst.Name == edge2.Dst.Name {
+			if edge1.Kind == edge2.Kind && edge1.Src.Name == edge2.Src.Name && edge1.Dst.Name == edge2.Dst.Name && edge1.Weight == edge2.Weight &&
+				edge1.ValidFrom.Equal(edge2.ValidFrom) && edge1.ValidTo.Equal(edge2.ValidTo) {
 				fmt.Printf("\rCompared edge number %d, name: %.20s", i, edge1.Name)
 				i++
 			} else {
-				log.Print("COMPARE_ERROR: mismatching EdgeKind for edge number ", i, ", name:", edge1.Name, "\n")
+				log.Print("COMPARE_ERROR: mismatching EdgeKind, nodes, weight or validity for edge number ", i, ", name:", edge1.Name, "\n")
 			}
 		} else {
 			log.Printf("COMPARE_ERROR: edge %q from network %s is missing in network %s\n", edge1.Name, n1.Name, n2.Name)
@@ -310,7 +583,7 @@ func compareNetworks(n1, n2 *Network) {
 	}
 	fmt.Println("")
 	if ee+en > 0 {
-		log.Printf("COMPARE_ERROR: FAIL. The Networks are different. %s nodes are missing, %s edges are missing. \n", en, ee)
+		log.Printf("COMPARE_ERROR: FAIL. The Networks are different. %d nodes are missing, %d edges are missing. \n", en, ee)
 	} else {
 		log.Printf("\n\nNetwoks %s and %s are similar\n", n1.Name, n2.Name)
 	}
@@ -327,16 +600,40 @@ func (n *Network) Compare(ns ...Network) {
 
 const TempSuffix = ".tmp"
 
+func (n *Network) dialect() Dialect {
+	d, err := dialectOf(n.DBDriver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return d
+}
+
+//openDB opens the database at fp with the dialect of the network.
+func (n *Network) openDB(fp string) (*sql.DB, Dialect) {
+	d := n.dialect()
+	db, err := d.Open(fp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db, d
+}
+
 func (n *Network) SaveAs(fp string) {
 	TempFilePath := fp + TempSuffix
 	ch := make(chan string)
-	os.Remove(TempFilePath)
+	d := n.dialect()
+	if err := d.Remove(TempFilePath); err != nil {
+		log.Fatal(err)
+	}
 	n.SaveNodes(TempFilePath, ch)
 	n.SaveEdges(TempFilePath, ch)
+	n.SaveAttributes(TempFilePath)
 	// for i := 0; i < 2; i++ {
 	// 	s <- ch
 	// }
-	os.Rename(TempFilePath, fp)
+	if err := d.Rename(TempFilePath, fp); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func (n *Network) Save() {
@@ -346,10 +643,7 @@ func (n *Network) Save() {
 func (n *Network) SaveNodes(fp string, ch chan string) {
 	fmt.Printf("Trying to save the nodes of network %q into file %q\n", n.Name, fp)
 	// Open/Create the database
-	db, err := sql.Open(n.DBDriver, fp)
-	if err != nil {
-		log.Fatal(err)
-	}
+	db, d := n.openDB(fp)
 	defer func() {
 		err := db.Close()
 		if err != nil {
@@ -357,8 +651,8 @@ func (n *Network) SaveNodes(fp string, ch chan string) {
 		}
 	}()
 	//Prepare & execute the table creation statement
-	sqlStmt := `CREATE TABLE nodes (name TEXT NOT NULL primary key, kind INT)`
-	_, err = db.Exec(sqlStmt)
+	sqlStmt := `CREATE TABLE nodes (name TEXT NOT NULL primary key, kind INT, datatype TEXT, data TEXT)`
+	_, err := db.Exec(sqlStmt)
 	if err != nil {
 		// log.Printf("%#v", err) //AL DEBUG
 		log.Printf("%q: %s\n", err, sqlStmt)
@@ -368,8 +662,8 @@ func (n *Network) SaveNodes(fp string, ch chan string) {
 	batchsize := 100000.
 	i := 0
 	var (
-		stmt *sql.Stmt
-		tx   *sql.Tx
+		loader BulkLoader
+		tx     *sql.Tx
 	)
 	for _, node := range n.Nodes {
 		// Open transaction and prepare statement
@@ -379,22 +673,25 @@ func (n *Network) SaveNodes(fp string, ch chan string) {
 			if err != nil {
 				log.Fatal(err)
 			}
-			stmt, err = tx.Prepare("INSERT INTO nodes(name, kind) values(?, ?)")
+			loader, err = d.BulkLoad(tx, "nodes", "name", "kind", "datatype", "data")
 			if err != nil {
 				log.Fatal(err)
 			}
-			defer stmt.Close()
 		}
 		// add Statements
 		fmt.Print("\r Adding statement for node ", i, "  ")
-		_, err = stmt.Exec(node.Name, node.Kind)
+		dataType, data, err := encodeData(node.NodeData)
+		if err != nil {
+			log.Printf("SAVE_NODES WARNING: data of node %q not saved: %s", node.Name, err)
+		}
+		err = loader.Add(node.Name, node.Kind, dataType, data)
 		if err != nil {
 			log.Fatal(err)
 		}
 		// Commit transaction
 		if math.Mod(float64(i+1), batchsize) == 0 || i == n.Nnodes-1 {
 			fmt.Println("\nComitting Transaction...")
-			tx.Commit()
+			commitBulk(tx, loader)
 		}
 		i++
 	}
@@ -404,13 +701,11 @@ func (n *Network) SaveNodes(fp string, ch chan string) {
 func (n *Network) SaveEdges(fp string, ch chan string) {
 	fmt.Printf("Trying to save the edges of network %q into file %q\n", n.Name, fp)
 	// Open/Create the database
-	db, err := sql.Open(n.DBDriver, fp)
-	if err != nil {
-		log.Fatal(err)
-	}
+	db, d := n.openDB(fp)
+	defer db.Close()
 	//Prepare & execute the table creation statement
-	sqlStmt := `CREATE TABLE edges (name TEXT NOT NULL primary key, kind INT, srcnode TEXT NOT NULL, dstnode TEXT NOT NULL)`
-	_, err = db.Exec(sqlStmt)
+	sqlStmt := `CREATE TABLE edges (name TEXT NOT NULL primary key, kind INT, srcnode TEXT NOT NULL, dstnode TEXT NOT NULL, weight REAL, valid_from TEXT, valid_to TEXT, datatype TEXT, data TEXT)`
+	_, err := db.Exec(sqlStmt)
 	if err != nil {
 		// log.Printf("%#v", err) //AL DEBUG
 		log.Printf("%q: %s\n", err, sqlStmt)
@@ -420,8 +715,8 @@ func (n *Network) SaveEdges(fp string, ch chan string) {
 	batchsize := 100000.
 	i := 0
 	var (
-		stmt *sql.Stmt
-		tx   *sql.Tx
+		loader BulkLoader
+		tx     *sql.Tx
 	)
 	for _, edge := range n.Edges {
 		// Open transaction and prepare statement
@@ -431,31 +726,44 @@ func (n *Network) SaveEdges(fp string, ch chan string) {
 			if err != nil {
 				log.Fatal(err)
 			}
-			stmt, err = tx.Prepare("INSERT INTO edges(name, kind, srcnode, dstnode) values(?, ?, ?, ?)")
+			loader, err = d.BulkLoad(tx, "edges", "name", "kind", "srcnode", "dstnode", "weight", "valid_from", "valid_to", "datatype", "data")
 			if err != nil {
 				log.Fatal(err)
 			}
-			defer stmt.Close()
 		}
 		// add Statements
 		fmt.Print("\r Adding statement for edge ", i, "  ")
-		_, err = stmt.Exec(edge.Name, edge.Kind, edge.Src.Name, edge.Dst.Name)
+		dataType, data, err := encodeData(edge.LinkData)
+		if err != nil {
+			log.Printf("SAVE_EDGES WARNING: data of edge %q not saved: %s", edge.Name, err)
+		}
+		err = loader.Add(edge.Name, edge.Kind, edge.Src.Name, edge.Dst.Name, edge.Weight, formatTime(edge.ValidFrom), formatTime(edge.ValidTo), dataType, data)
 		if err != nil {
 			log.Fatal(err)
 		}
 		// Commit transaction
 		if math.Mod(float64(i+1), batchsize) == 0 || i == n.Nedges-1 {
 			fmt.Println("\nComitting Transaction...")
-			tx.Commit()
+			commitBulk(tx, loader)
 		}
 		i++
 	}
 	// ch <- "edge"
 }
 
+func commitBulk(tx *sql.Tx, loader BulkLoader) {
+	if err := loader.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func (n *Network) LoadFrom(filePath string) {
 	n.LoadNodes(filePath)
 	n.LoadEdges(filePath)
+	n.LoadAttributes(filePath)
 }
 
 func (n *Network) Load() {
@@ -466,21 +774,31 @@ func (n *Network) Load() {
 func (n *Network) LoadNodes(fp string) {
 	fmt.Printf("Trying to load the nodes into network %q from file %q\n", n.Name, fp)
 	// Open/Create the database
-	db, err := sql.Open(n.DBDriver, fp)
-	if err != nil {
-		log.Fatal(err)
-	}
+	db, _ := n.openDB(fp)
+	defer db.Close()
 	//Retrieve the data
-	rows, err := db.Query("SELECT name, kind FROM nodes")
-	if err != nil {
-		log.Fatal(err)
-	}
+	rows, fallback := queryFallback(db,
+		"SELECT name, kind, datatype, data FROM nodes",
+		"SELECT name, kind FROM nodes")
+	withData := fallback == 0
 	i := 0
 	sn := SimpleNoder{}
+	var dataType, data sql.NullString
 	for rows.Next() {
-		rows.Scan(&sn.Name, &sn.Kind)
+		if withData {
+			rows.Scan(&sn.Name, &sn.Kind, &dataType, &data)
+		} else {
+			rows.Scan(&sn.Name, &sn.Kind)
+		}
 		fmt.Print("\r Adding node number ", i, " in the network.")
 		n.AddNode(&sn)
+		if dataType.Valid {
+			if nodeData, err := decodeData(dataType.String, data.String); err != nil {
+				log.Printf("LOAD_NODES WARNING: data of node %q not loaded: %s", sn.Name, err)
+			} else {
+				n.Nodes[sn.Name].NodeData = nodeData
+			}
+		}
 		i++
 	}
 	fmt.Println()
@@ -490,27 +808,68 @@ func (n *Network) LoadNodes(fp string) {
 func (n *Network) LoadEdges(fp string) {
 	fmt.Printf("Trying to load the edges into network %q from file %q\n", n.Name, fp)
 	// Open/Create the database
-	db, err := sql.Open(n.DBDriver, fp)
-	if err != nil {
-		log.Fatal(err)
-	}
+	db, _ := n.openDB(fp)
+	defer db.Close()
 	//Retrivee the data
-	rows, err := db.Query("SELECT name, kind, srcnode, dstnode FROM edges")
-	if err != nil {
-		log.Fatal(err)
-	}
-	se := SimpleEdger{}
+	rows, fallback := queryFallback(db,
+		"SELECT name, kind, srcnode, dstnode, weight, valid_from, valid_to, datatype, data FROM edges",
+		"SELECT name, kind, srcnode, dstnode, weight, valid_from, valid_to FROM edges",
+		"SELECT name, kind, srcnode, dstnode FROM edges")
+	se := SimpleEdger{HasWeight: fallback < 2} // DefaultWeight and no validity for the files saved without them
+	var (
+		validFrom, validTo string
+		dataType, data     sql.NullString
+		err                error
+	)
 	i := 0
 	for rows.Next() {
-		rows.Scan(&se.Name, &se.Kind, &se.SrcId, &se.DstId)
+		switch fallback {
+		case 0:
+			rows.Scan(&se.Name, &se.Kind, &se.SrcId, &se.DstId, &se.Weight, &validFrom, &validTo, &dataType, &data)
+		case 1:
+			rows.Scan(&se.Name, &se.Kind, &se.SrcId, &se.DstId, &se.Weight, &validFrom, &validTo)
+		default:
+			rows.Scan(&se.Name, &se.Kind, &se.SrcId, &se.DstId)
+		}
+		if se.ValidFrom, err = parseTime(validFrom); err != nil {
+			log.Printf("LOAD_EDGES WARNING: bad validity for edge %q: %s", se.Name, err)
+		}
+		if se.ValidTo, err = parseTime(validTo); err != nil {
+			log.Printf("LOAD_EDGES WARNING: bad validity for edge %q: %s", se.Name, err)
+		}
 		fmt.Print("\r Adding edge number ", i, " in the network.")
 		n.AddEdge(&se)
+		if dataType.Valid {
+			if linkData, err := decodeData(dataType.String, data.String); err != nil {
+				log.Printf("LOAD_EDGES WARNING: data of edge %q not loaded: %s", se.Name, err)
+			} else if edge, ok := n.Edges[n.edgeKey(&se)]; ok {
+				edge.LinkData = linkData
+				n.indexOriginal(edge)
+			}
+		}
 		i++
 	}
 	fmt.Println()
 	rows.Close()
 }
 
+//queryFallback runs the first of the queries the file can answer, with its index: the files saved
+//by older versions lack the newer columns (the weights and validities, then the data).
+func queryFallback(db *sql.DB, queries ...string) (*sql.Rows, int) {
+	for i, query := range queries[:len(queries)-1] {
+		rows, err := db.Query(query)
+		if err == nil {
+			return rows, i
+		}
+		log.Printf("LOAD WARNING: loading without the newer columns: %s", err)
+	}
+	rows, err := db.Query(queries[len(queries)-1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rows, len(queries) - 1
+}
+
 //-----------------------
 //SECTION 3: NON-COMPLEX NETWORK OPERATIONS
 func (n *Network) Search(namePattern string, mode string) {
@@ -578,6 +937,91 @@ func (n *Network) CheckSubNetworkNodes(subNetwork map[*Node]bool) bool {
 	return true
 }
 
+//Projection of a bipartite network --
+//The hubs (e.g. filings) are replaced by direct edges between the nodes they connect, named
+//src_hub_dst: EE between the sources of the hub edges, RR between their destinations, ER from the
+//sources to the destinations. The projected edges carry the data of the hub edge of their source,
+//and are valid while both hub edges are.
+
+type projectedEdger struct {
+	SimpleEdger
+	data AttrGetter
+}
+
+func (pe *projectedEdger) GetData() AttrGetter {
+	return pe.data
+}
+
+func (n *Network) Project(hubKind NodeKind) Network {
+	sub := *n // Same parameters
+	sub.Name = n.Name + "_projected"
+	sub.Edges, sub.Nedges, sub.originals = make(map[string]*Edge), 0, nil
+	sub.Nodes, sub.Nnodes = make(map[string]*Node), 0
+	sub.PersistingFile = sub.Name + ".sqlite"
+	hubs := []*Node{}
+	for _, node := range n.Nodes {
+		if node.Kind == hubKind {
+			hubs = append(hubs, node)
+		} else {
+			sub.copyNode(node)
+		}
+	}
+	for _, edge := range n.Edges {
+		if edge.Src.Kind != hubKind && edge.Dst.Kind != hubKind {
+			sub.connect(&Edge{edge.Name, edge.Kind, sub.Nodes[edge.Src.Name], sub.Nodes[edge.Dst.Name],
+				edge.Weight, edge.ValidFrom, edge.ValidTo, edge.LinkData})
+		}
+	}
+	for _, hub := range hubs {
+		// Sort the hub edges by side
+		sources, destinations := []*Edge{}, []*Edge{}
+		seen := map[*Edge]bool{}
+		for _, ens := range [][]*EdgeToNode{hub.Edges, hub.InEdges} {
+			for _, en := range ens {
+				if seen[en.Edge] || en.ToNode.Kind == hubKind {
+					continue
+				}
+				seen[en.Edge] = true
+				if en.Dst == hub {
+					sources = append(sources, en.Edge)
+				} else {
+					destinations = append(destinations, en.Edge)
+				}
+			}
+		}
+		project := func(kind EdgeKind, e1, e2 *Edge, srcId, dstId string) {
+			if kind != ER && dstId < srcId { // Same order as the Filing edges
+				srcId, dstId = dstId, srcId
+			}
+			from, to := e1.ValidFrom, e1.ValidTo
+			if from.IsZero() || e2.ValidFrom.After(from) {
+				from = e2.ValidFrom
+			}
+			if to.IsZero() || (!e2.ValidTo.IsZero() && e2.ValidTo.Before(to)) {
+				to = e2.ValidTo
+			}
+			sub.AddEdge(&projectedEdger{
+				SimpleEdger{srcId + "_" + hub.Name + "_" + dstId, kind, srcId, dstId, DefaultWeight, true, from, to, nil},
+				e1.LinkData,
+			})
+		}
+		for i, s := range sources {
+			for _, s2 := range sources[i+1:] {
+				project(EE, s, s2, s.Src.Name, s2.Src.Name)
+			}
+			for _, d := range destinations {
+				project(ER, s, d, s.Src.Name, d.Dst.Name)
+			}
+		}
+		for i, d := range destinations {
+			for _, d2 := range destinations[i+1:] {
+				project(RR, d, d2, d.Dst.Name, d2.Dst.Name)
+			}
+		}
+	}
+	return sub
+}
+
 //-----------------------
 //SECTION 4: SUBNETWORK DETECTION
 //A.
@@ -604,7 +1048,7 @@ func detectSubsVertical(startNode *Node, maxN int, subNetwork map[*Node]bool) bo
 		return false
 	}
 	subNetwork[startNode] = true
-	if len(startNode.Edges) == 1 { // This is a dead-end (the only edge is the one it comes from)
+	if len(startNode.Edges) == 0 || (len(startNode.Edges) == 1 && subNetwork[startNode.Edges[0].ToNode]) { // This is a dead-end (the only edge is the one it comes from)
 		return true
 	}
 	for _, e := range startNode.Edges {
@@ -618,9 +1062,10 @@ func detectSubsVertical(startNode *Node, maxN int, subNetwork map[*Node]bool) bo
 //B.
 //"Depth-first", but concurrent. Should be quite efficient.
 //There is no synchronization mechanism (on purpose), so it is not maxN-reproducible.
+//Each routine answers to the previous level whether its branch is complete: the subnetwork is complete
+//only when all the branches are.
 type ComObject struct {
 	cs    []chan bool
-	res   *[]bool
 	debug *uint64 //DEBUG
 }
 type ccrSubNetwork struct {
@@ -632,7 +1077,6 @@ func CcrDetectSubsVertical(startNode *Node, maxN int) (map[string]bool, bool) {
 	subNetwork := &ccrSubNetwork{m: make(map[string]bool)}
 	subNetwork.m[startNode.Name] = true
 	co := ComObject{}
-	co.res = &[]bool{}
 	var init uint64 = 0
 	co.debug = &init
 	//Prepare the communication channels
@@ -648,23 +1092,12 @@ func CcrDetectSubsVertical(startNode *Node, maxN int) (map[string]bool, bool) {
 		subNetwork.Unlock()
 		go ccrDetectSubsVertical(e.ToNode, maxN-1, subNetwork, co)
 	}
+	isSub := true
 	for i := 0; i < len(startNode.Edges); i++ {
-		*co.res = append(*co.res, <-co.cs[maxN-1])
+		isSub = <-co.cs[maxN-1] && isSub
 	}
 	// fmt.Println("") //DEBUG
-	//Count the results
-	nF := 0
-	nT := 0
-	for _, t := range *co.res {
-		if t {
-			nT++
-		} else {
-			nF++
-		}
-	}
-	// fmt.Println(nF, "Falses", nT, "Trues")                    //DEBUG             // DEBUG
 	// fmt.Println("Number of go routines launched:", *co.debug) //DEBUG
-	isSub := nT > nF //Not a good criterion
 	return subNetwork.m, isSub
 }
 
@@ -682,12 +1115,10 @@ func ccrDetectSubsVertical(startNode *Node, maxN int, subNetwork *ccrSubNetwork,
 		n := e.ToNode
 		subNetwork.Lock()
 		isIn := subNetwork.m[n.Name]
+		subNetwork.m[n.Name] = true // Add the current node to the subnetwork
 		subNetwork.Unlock()
 		if !isIn {
-			subNetwork.Lock()
-			subNetwork.m[n.Name] = true // Add the current node to the subnetwork
-			subNetwork.Unlock()
-			if len(n.Edges) != 1 { //Launch next step only if not a dead end
+			if len(n.Edges) > 1 || (len(n.Edges) == 1 && n.Edges[0].ToNode != startNode) { //Launch next step only if not a dead end
 				nNewNodes++
 				// atomic.AddUint64(co.debug, 1) //DEBUG
 				go ccrDetectSubsVertical(n, maxN-1, subNetwork, co)
@@ -695,17 +1126,14 @@ func ccrDetectSubsVertical(startNode *Node, maxN int, subNetwork *ccrSubNetwork,
 		}
 	}
 	// fmt.Println("this detection on node", startNode.Name, "led to", nNewNodes, "new nodes to discover") //DEBUG
-	if nNewNodes == 0 {
-		// fmt.Println("[detection on node", startNode.Name, "]: Sending 1 TRUE") //DEBUG
-		co.cs[maxN] <- true
-	} else { //Undetermined counting!!
-		// fmt.Println("[detection on node", startNode.Name, "]: Waiting for", nNewNodes, "answers") //DEBUG
-		for i := 0; i < nNewNodes; i++ {
-			*co.res = append(*co.res, <-co.cs[maxN-1])
-		}
-		// fmt.Println("[detection on node", startNode.Name, "]: Gathering answers, Sending 1 TRUE") //DEBUG
-		co.cs[maxN] <- true
+	//The routines of a level share their channel, so the answers gathered are not always the ones of
+	//the branches launched here. They are only combined though: an incomplete branch is always passed on.
+	// fmt.Println("[detection on node", startNode.Name, "]: Waiting for", nNewNodes, "answers") //DEBUG
+	isComplete := true
+	for i := 0; i < nNewNodes; i++ {
+		isComplete = <-co.cs[maxN-1] && isComplete
 	}
+	co.cs[maxN] <- isComplete
 }
 
 //C.
@@ -831,27 +1259,20 @@ func (net *Net) Summary(w io.Writer) {
 
 func (net *Net) AddSub(subN map[*Node]bool) {
 	iSub := len(net.SubNetworks)
-	if iSub == 0 {
-		net.SubNetworks[0] = make(map[*Node]bool)
-		iSub = 1
-	}
 	net.SubNetworks[iSub] = subN
 	for k, _ := range subN {
 		net.NodeMap[k] = iSub
 	}
 }
 
+//CrunchNetwork adds the connected components of the network (see Network.Components) that are not in the net yet.
 func (net *Net) CrunchNetwork(n *Network) {
-	maxNratio := 1.2
-	for _, node := range n.Nodes {
-		if net.NodeMap[node] > 0 {
+	c := n.Components()
+	for id, members := range c.Members {
+		if _, ok := net.NodeMap[members[0]]; ok {
 			continue
 		}
-		subN, isSub := DetectSubsVertical(node, int(maxNratio*float64(len(n.Nodes))))
-		if !isSub {
-			panic("CRUNCHNETWORK: maximum iteration number is not enough to detect the biggest subnetwork")
-		}
-		net.AddSub(subN)
+		net.AddSub(c.SubNetwork(id))
 	}
 }
 
@@ -872,6 +1293,9 @@ func (sw *SimpleWanderer) DetectSubs(startNode *Node, maxN int) (map[string]bool
 	subNetwork := map[string]bool{
 		startNode.Name: true,
 	}
+	if len(startNode.Edges) == 0 { // Nowhere to go
+		return subNetwork, true
+	}
 	if len(startNode.Edges) > 1 {
 		for _, e := range startNode.Edges[1:] {
 			subNetwork[e.ToNode.Name] = true
@@ -896,7 +1320,7 @@ func (sw *SimpleWanderer) DetectSubs(startNode *Node, maxN int) (map[string]bool
 			// fmt.Printf("Trying Node %p with stack %v :\n", n, sw.Moignons) //DEBUG
 			if !subNetwork[n.Name] {
 				subNetwork[n.Name] = true
-				if len(n.Edges) != 1 { // That would be a dead-end
+				if len(n.Edges) > 1 || (len(n.Edges) == 1 && !subNetwork[n.Edges[0].ToNode.Name]) { // That would be a dead-end
 					if hasNext {
 						sw.Moignons.Push(n)
 					} else {
@@ -918,57 +1342,34 @@ func (sw *SimpleWanderer) DetectSubs(startNode *Node, maxN int) (map[string]bool
 }
 
 //Wandering function, similar to the DetectSub above, but with embedded duplicity to enable
-//lightweight communication
+//lightweight communication. It expands maxN nodes at most, following the edges whatever their direction,
+//and returns the nodes found. Called again, it goes on from where it stopped; it is done when its subnetwork is complete.
 func (sw *SimpleWanderer) Wander(startNode *Node, maxN int) (map[*Node]bool, bool) {
-	//Initialization
-	sw.SubNetwork[startNode] = true
-	subNetworkIncrement := map[*Node]bool{
-		startNode: true,
+	subNetworkIncrement := map[*Node]bool{}
+	if !sw.SubNetwork[startNode] { //Initialization
+		sw.SubNetwork[startNode] = true
+		subNetworkIncrement[startNode] = true
+		sw.Moignons.Push(startNode)
 	}
-	if len(startNode.Edges) > 1 {
-		for _, e := range startNode.Edges[1:] {
-			n := e.ToNode
-			sw.SubNetwork[n] = true
-			subNetworkIncrement[n] = true
-			sw.Moignons.Push(e.ToNode)
-		}
-	}
-	// Add the first Node
-	n := startNode.Edges[0].ToNode
-	currentNode := n
-	sw.SubNetwork[n] = true
-	subNetworkIncrement[n] = true
 	//Wander for maxN steps
-	for i := 0; i < maxN; i++ {
-		// fmt.Printf("Wandering on Node %p with stack %v :\n", currentNode, sw.Moignons) //DEBUG
-		hasNext := false
-		for _, e := range currentNode.Edges {
-			n := e.ToNode
-			// fmt.Printf("Trying Node %p with stack %v :\n", n, sw.Moignons) //DEBUG
-			if !sw.SubNetwork[n] {
-				sw.SubNetwork[n] = true
-				subNetworkIncrement[n] = true
-				if len(n.Edges) != 1 { // That would be a dead-end
-					if hasNext {
-						sw.Moignons.Push(n)
-					} else {
-						hasNext = true
-						currentNode = n
-					}
+	for i := 0; i < maxN && len(*sw.Moignons) > 0; i++ {
+		currentNode := sw.Moignons.Pop()
+		for _, ens := range [][]*EdgeToNode{currentNode.Edges, currentNode.InEdges} {
+			for _, e := range ens {
+				if n := e.ToNode; !sw.SubNetwork[n] {
+					sw.SubNetwork[n] = true
+					subNetworkIncrement[n] = true
+					sw.Moignons.Push(n)
 				}
 			}
 		}
-		if !hasNext { //Go back to the most recent Moignon or exit if done
-			if len(*sw.Moignons) > 0 {
-				currentNode = sw.Moignons.Pop()
-			} else {
-				return subNetworkIncrement, true
-			}
-		}
 	}
-	return subNetworkIncrement, false
+	return subNetworkIncrement, len(*sw.Moignons) == 0
 }
 
+//Orders between a wanderer and its coordinator: after each step, the wanderer reports that it can Continue
+//or is Done, and is told to Continue, to Merge with the wanderers sent, to Break (and hand itself over
+//for merging) or that it is Done.
 type Order int
 
 const (
@@ -1003,57 +1404,47 @@ func (sw *SimpleWanderer) Merge(sw2 *SimpleWanderer) {
 	}
 }
 
+//WanderStep wanders stepSize nodes at a time, reporting its status and the nodes found to its coordinator
+//(see Network.CcrComponents) after each step, and following its orders.
 func (sw *SimpleWanderer) WanderStep(startNode *Node, stepSize int, com WandererCom) {
 	for {
-		//Wander for stepSize
+		//Wander for stepSize and report
 		subN, done := sw.Wander(startNode, stepSize)
+		if done {
+			com.cOrder <- Done
+		} else {
+			com.cOrder <- Continue
+		}
+		com.cSubN <- subN
 		//Receive orders
-		switch <-com.cOrder {
+		order := <-com.cOrder
+		for ; order == Merge; order = <-com.cOrder { //Merge with an other wanderer, and wait for the next order
+			sw.Merge(<-com.cWanderer)
+		}
+		switch order {
 		case Continue: // Go on!
-		case Break: // Stop and pass the subNetwork & the wanderer for merging
-			com.cSubN <- sw.SubNetwork
+		case Break: // Stop and pass the wanderer for merging
 			com.cWanderer <- sw
 			return
-		case Merge: //Merge with an other wanderer and go on
-			sw.Merge(<-com.cWanderer)
+		case Done: // The subnetwork is complete
+			return
 		default:
 			panic("WANDERSTEP: problem of communication")
 		}
-		if done {
-			com.cOrder <- Done
-			com.cSubN <- sw.SubNetwork
-			return
-		} else {
-			//Ask for Status
-			com.cOrder <- Continue //Ask for permission to continue
-			com.cSubN <- subN
-		}
-	}
-}
-
-// func (net *Net) CcrCrunchNetwork(n *Network, maxW int, stepSize int) {
-// 	//Initialize the stuff
-// 	comObjects := []*WandererCom{}
-// 	wanderers := []*SimpleWanderer{}
-// 	i:=0
-// 	for _, node := range n.Nodes { // Populate with the number of wanderers
-// 		if i == maxW { // Stop at maxW
-// 			break
-// 		}
-// 		comObjects[i] = NewWandererCom()
-// 		wanderers[i] = NewSimpleWanderer()
-// 		go wanderers[i].WanderStep()
-// 		i++
-// 		}
-// 	}
-// 	// Listen and dispatch.
-// 	for {
-// 		for i:=0; i < maxW; i++ {
-// 			select
-// 			net.Dispatch(comObjects[i])
-// 		}
-// 	}
-// }
+	}
+}
+
+//F.
+//Concurrent crunching, with wanderers merging when their frontiers meet (see Network.CcrComponents).
+func (net *Net) CcrCrunchNetwork(n *Network, nWorkers int) {
+	c := n.CcrComponents(nWorkers)
+	for id, members := range c.Members {
+		if _, ok := net.NodeMap[members[0]]; ok {
+			continue
+		}
+		net.AddSub(c.SubNetwork(id))
+	}
+}
 
 //---------------------
 //SECTION 5: PAGERANK
@@ -1141,7 +1532,15 @@ func (rw *RandomWalker) Next() *Node {
 		// fmt.Printf("Restarting from Node %20.20s to node %20.20s. \n", rw.state.Name, n.Name)
 	} else {
 		p = p / (1.0 - rw.restartProb)
-		i := int(math.Floor(float64(p) * float64(l)))
+		// Pick the edge with a probability proportional to its weight
+		w := p * rw.state.Strength()
+		i := 0
+		for ; i < l-1; i++ {
+			w = w - rw.state.Edges[i].Weight
+			if w < 0 {
+				break
+			}
+		}
 		// fmt.Printf("Edge number %4d / %4d (p=%.2f) of Node %20.20s. \n", i, len(rw.state.Edges), p, rw.state.Name)
 		n = rw.state.Edges[i].ToNode
 	}
@@ -1151,8 +1550,13 @@ func (rw *RandomWalker) Next() *Node {
 
 //Walk for nStep steps.
 func (rw *RandomWalker) Walk(nStep int, c chan<- *Counter, done chan<- int) {
+	rw.WalkContext(context.Background(), nStep, c, done)
+}
+
+//WalkContext stops walking when the context is cancelled, done is always signaled.
+func (rw *RandomWalker) WalkContext(ctx context.Context, nStep int, c chan<- *Counter, done chan<- int) {
 	i := 0
-	for i < nStep {
+	for i < nStep && ctx.Err() == nil {
 		counter := NewCounter()
 		for j := 0; j < 1000; j++ {
 			counter.Add(rw.Next())
@@ -1169,6 +1573,12 @@ func (rw *RandomWalker) Walk(nStep int, c chan<- *Counter, done chan<- int) {
 //Applicable in case of large networks if non regular
 //OR for personalization (seeds as a subset)
 func (nn *Network) PageRankRW(nRW, nSteps int, seeds []*Node) map[*Node]float32 {
+	pi, _ := nn.PageRankRWContext(context.Background(), nRW, nSteps, seeds)
+	return pi
+}
+
+//PageRankRWContext returns the error of the context (and no ranks) when it is cancelled before the walkers are done.
+func (nn *Network) PageRankRWContext(ctx context.Context, nRW, nSteps int, seeds []*Node) (map[*Node]float32, error) {
 	//Unpack arguments and prepare seeds
 	if seeds == nil {
 		seeds = make([]*Node, len(nn.Nodes))
@@ -1195,18 +1605,21 @@ func (nn *Network) PageRankRW(nRW, nSteps int, seeds []*Node) map[*Node]float32
 
 	//Launch the walk of the random walkers
 	for _, rwi := range RWs {
-		go rwi.Walk(nSteps, cCounter, done)
+		go rwi.WalkContext(ctx, nSteps, cCounter, done)
 	}
 
 	//Collect data
 	passCounter.Listen(cCounter, done, nRW)
 
-	return passCounter.Normalize()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return passCounter.Normalize(), nil
 }
 
 //Simple PageRank implementation based on node degree information.
 //Applicable only for regular symetric networks. No Edge strengh is
-//checked. (mainly because the lack of implementation so far)
+//checked (see PageRankSymmetric for the weighted version).
 func (n *Network) PageRankSymmetricRegular() map[*Node]float32 {
 	counter := NewCounter()
 	for _, node := range n.Nodes {
@@ -1217,9 +1630,8 @@ func (n *Network) PageRankSymmetricRegular() map[*Node]float32 {
 	return counter.Normalize()
 }
 
-//Simple PageRank implementation based on node degree information.
+//Simple PageRank implementation based on node strength (summed edge weights).
 //Applicable only for symetric networks.
-//Everything compiles but the missing property Edge.Weight
 func (n *Network) PageRankSymmetric() map[*Node]float32 {
 	//Create a float counter
 	counter := struct {
@@ -1231,11 +1643,7 @@ func (n *Network) PageRankSymmetric() map[*Node]float32 {
 	}
 	//Iterate over the nodes of the network
 	for _, node := range n.Nodes {
-		deg := float32(0)
-		for _, e := range node.Edges {
-			w := float32(e.Kind) //TODO: -> e.Weight
-			deg = deg + w
-		}
+		deg := node.Strength()
 		counter.weight[node] = deg
 		counter.totalWeight = counter.totalWeight + float64(deg)
 	}
@@ -1295,14 +1703,16 @@ func (nn *Network) GetSortedLUT() Nlut {
 	return LUT
 }
 
-//GetAMatrix returns the adjacency matrix of the network.
+//GetAMatrix returns the (weighted) adjacency matrix of the network.
+//Parallel edges between two nodes add up their weights.
 func (nn *Network) GetAMatrix() (*mat64.Dense, Nlut) {
 	nNodes := len(nn.Nodes)
 	A := mat64.NewDense(nNodes, nNodes, nil)
 	LUT := nn.GetSortedLUT()
 	for i, n := range LUT.nlut {
 		for _, e := range n.Edges {
-			A.Set(i, LUT.ilut[e.ToNode], 1)
+			j := LUT.ilut[e.ToNode]
+			A.Set(i, j, A.At(i, j)+float64(e.Weight))
 		}
 	}
 	return A, LUT
@@ -1313,7 +1723,13 @@ func (nn *Network) GetDMatrix() (*mat64.Dense, Nlut) {
 	nr, _ := D.Dims()
 	for r := 0; r < nr; r++ {
 		row := D.Row(nil, r)
-		floats.Scale(1.0/floats.Sum(row), row)
+		if sum := floats.Sum(row); sum > 0 {
+			floats.Scale(1.0/sum, row)
+		} else { // Dangling node (no out-edge in a directed network): jump anywhere
+			for c := range row {
+				row[c] = 1.0 / float64(nr)
+			}
+		}
 		D.SetRow(r, row)
 	}
 	return D, LUT
@@ -3,6 +3,8 @@ package go_nets
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/base64"
 	"fmt"
 	"image"
@@ -36,7 +38,7 @@ func loadNetwork(name string, writer io.Writer) Network {
 	network.Load()
 	fmt.Printf("\n Successfully loaded the network in %v \n", time.Now().Sub(t0))
 	network.Summary(os.Stdout)
-	fmt.Println("### ---------------\n")
+	fmt.Println("### ---------------")
 	return network
 }
 
@@ -124,7 +126,7 @@ func TestPipeline(t *testing.T) {
 	t0 := time.Now()
 	network.Save()
 	fmt.Printf("\n Successfully saved the network in %v \n", time.Now().Sub(t0))
-	fmt.Println("### ---------------\n")
+	fmt.Println("### ---------------")
 
 	//Loading the network
 	fmt.Println("Loading network")
@@ -133,14 +135,14 @@ func TestPipeline(t *testing.T) {
 	network2.Load()
 	fmt.Printf("\n Successfully loaded the network in %v \n", time.Now().Sub(t0))
 	network2.Summary(os.Stdout)
-	fmt.Println("### ---------------\n")
+	fmt.Println("### ---------------")
 
 	//Comparing the networks
 	fmt.Println("Comparing networks")
 	t0 = time.Now()
 	network.Compare(network2)
 	fmt.Printf("\n Successfully compared the networks in %v \n", time.Now().Sub(t0))
-	fmt.Println("### ---------------\n")
+	fmt.Println("### ---------------")
 
 	//Looking into the networs
 	namePattern := "desert.*car"
@@ -151,7 +153,7 @@ func TestPipeline(t *testing.T) {
 	fmt.Println("In network", network2.Name)
 	network2.Search(namePattern, "edge")
 	fmt.Printf("\n Successfully searched the networks in %v \n", time.Now().Sub(t0))
-	fmt.Println("### ---------------\n")
+	fmt.Println("### ---------------")
 }
 
 func TestLoad(t *testing.T) {
@@ -448,3 +450,269 @@ func TestPageRank(t *testing.T) {
 	fmt.Println("Done in", t1)
 
 }
+
+func TestPageRankCancel(t *testing.T) {
+	fmt.Println("### TESTING the cancellation of the random walks")
+	network := newTestNetwork("TestPageRankCancel", [][3]string{{"a", "b", "ER"}, {"b", "c", "ER"}, {"c", "a", "ER"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	t0 := time.Now()
+	pi, err := network.PageRankRWContext(ctx, 4, 1e9, nil)
+	if err != context.DeadlineExceeded || pi != nil {
+		t.Errorf("Got %v (%d ranks), expected %v", err, len(pi), context.DeadlineExceeded)
+	}
+	if d := time.Now().Sub(t0); d > time.Second {
+		t.Errorf("The walkers took %v to stop", d)
+	}
+	if pi, err = network.PageRankRWContext(context.Background(), 2, 1e4, nil); err != nil || len(pi) != 3 {
+		t.Errorf("Got %v (%d ranks), expected the 3 nodes ranked", err, len(pi))
+	}
+}
+
+// Build a small in-memory network (no input file needed) from a list of {src, dst, kind} edges.
+func newTestNetwork(name string, edges [][3]string) Network {
+	network := NewNetwork(name, ioutil.Discard, testFolder)
+	addTestEdges(&network, edges)
+	return network
+}
+
+func addTestEdges(network *Network, edges [][3]string) {
+	for _, e := range edges {
+		for _, id := range e[:2] {
+			network.AddNode(&SimpleNoder{Name: id, Kind: Emitter})
+		}
+		kind := ER
+		switch e[2] {
+		case "EE":
+			kind = EE
+		case "RR":
+			kind = RR
+		}
+		network.AddEdge(&SimpleEdger{Name: e[0] + "_" + e[1], Kind: kind, SrcId: e[0], DstId: e[1]})
+	}
+}
+
+func TestWeights(t *testing.T) {
+	fmt.Println("### TESTING the edge weights")
+	edges := [][3]string{{"a", "b", "ER"}, {"a", "b", "ER"}, {"a", "c", "ER"}, {"a", "b", "ER"}}
+	for _, test := range []struct {
+		name       string
+		aggregator WeightAggregator
+		expected   float32
+	}{
+		{"count", CountWeights, 3},
+		{"sum", SumWeights, 3},
+		{"max", MaxWeights, 1},
+		{"latest", LatestWeight, 1},
+		{"none", nil, 1},
+	} {
+		network := NewNetwork("TestWeights", ioutil.Discard, testFolder)
+		network.Aggregator = test.aggregator
+		addTestEdges(&network, edges)
+		if w := network.Edges["a_b"].Weight; w != test.expected {
+			t.Errorf("Aggregating with %s: got weight %v, expected %v", test.name, w, test.expected)
+		}
+		if network.Nedges != 2 {
+			t.Errorf("Aggregating with %s: got %d edges, expected 2", test.name, network.Nedges)
+		}
+	}
+	// Aggregate by pair of nodes rather than by identifier
+	network := NewNetwork("TestWeights", ioutil.Discard, testFolder)
+	network.EdgeKey = PairKey
+	network.AddNode(&SimpleNoder{Name: "a", Kind: Emitter})
+	network.AddNode(&SimpleNoder{Name: "b", Kind: Receiver})
+	network.AddEdge(&SimpleEdger{Name: "a_1_b", Kind: ER, SrcId: "a", DstId: "b", Weight: 2})
+	network.AddEdge(&SimpleEdger{Name: "a_2_b", Kind: ER, SrcId: "a", DstId: "b"})
+	if network.Nedges != 1 || network.Nodes["a"].Strength() != 3 {
+		t.Errorf("Aggregating by pair: got %d edges of strength %v, expected 1 of strength 3", network.Nedges, network.Nodes["a"].Strength())
+	}
+	// The weights drive the pagerank
+	network = newTestNetwork("TestWeights", edges)
+	pi := network.PageRankSymmetric()
+	if pi[network.Nodes["b"]] <= pi[network.Nodes["c"]] {
+		t.Errorf("PageRankSymmetric: node b (%v) should weigh more than node c (%v)", pi[network.Nodes["b"]], pi[network.Nodes["c"]])
+	}
+	// And are persisted
+	network.SaveAs(testFolder + "TestWeights.sqlite")
+	network2 := NewNetwork("TestWeights2", ioutil.Discard, testFolder)
+	network2.LoadFrom(testFolder + "TestWeights.sqlite")
+	if w := network2.Edges["a_b"].Weight; w != 3 {
+		t.Errorf("Loading the weights: got %v, expected 3", w)
+	}
+}
+
+func TestZeroWeight(t *testing.T) {
+	fmt.Println("### TESTING the edges of weight 0")
+	network := NewNetwork("TestZeroWeight", ioutil.Discard, testFolder)
+	network.AddNode(&SimpleNoder{Name: "a", Kind: Emitter})
+	network.AddNode(&SimpleNoder{Name: "b", Kind: Receiver})
+	network.AddEdge(&SimpleEdger{Name: "a_b", Kind: ER, SrcId: "a", DstId: "b", HasWeight: true})
+	if w := network.Edges["a_b"].Weight; w != 0 {
+		t.Fatalf("Adding: got weight %v, expected 0", w)
+	}
+	network.SaveAs(testFolder + "TestZeroWeight.sqlite")
+	loaded := NewNetwork("TestZeroWeight2", ioutil.Discard, testFolder)
+	loaded.LoadFrom(testFolder + "TestZeroWeight.sqlite")
+	var graphML, csv bytes.Buffer
+	if err := network.WriteGraphML(&graphML); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.WriteEdgeList(&csv, CSV); err != nil {
+		t.Fatal(err)
+	}
+	imported := NewNetwork("TestZeroWeight3", ioutil.Discard, testFolder)
+	if err := imported.ReadGraphML(&graphML); err != nil {
+		t.Fatal(err)
+	}
+	listed := NewNetwork("TestZeroWeight4", ioutil.Discard, testFolder)
+	if err := listed.ReadEdgeList(&csv, CSV); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*Network{&loaded, &imported, &listed} {
+		if e := n.Edges["a_b"]; e == nil || e.Weight != 0 {
+			t.Errorf("Round trip through %s: got edge %v, expected a weight of 0", n.Name, e)
+		}
+	}
+}
+
+func TestLoadOldSchema(t *testing.T) {
+	fmt.Println("### TESTING the loading of the files saved without weights, validities and data")
+	fp := testFolder + "TestLoadOldSchema.sqlite"
+	os.Remove(fp)
+	db, err := sql.Open("sqlite3", fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE nodes (name TEXT NOT NULL primary key, kind INT)`,
+		`CREATE TABLE edges (name TEXT NOT NULL primary key, kind INT, srcnode TEXT NOT NULL, dstnode TEXT NOT NULL)`,
+		`INSERT INTO nodes VALUES ('a', 0), ('b', 1)`,
+		`INSERT INTO edges VALUES ('a_b', 0, 'a', 'b')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	network := NewNetwork("TestLoadOldSchema", ioutil.Discard, testFolder)
+	network.LoadNodes(fp)
+	network.LoadEdges(fp)
+	e := network.Edges["a_b"]
+	if e == nil || e.Weight != DefaultWeight || !e.ValidFrom.IsZero() || !e.ValidTo.IsZero() {
+		t.Errorf("Got edge %+v, expected a_b with the default weight and no validity", e)
+	}
+}
+
+func TestDirected(t *testing.T) {
+	fmt.Println("### TESTING the directed mode")
+	network := NewNetwork("TestDirected", ioutil.Discard, testFolder)
+	network.Symmetrical = false
+	addTestEdges(&network, [][3]string{{"a", "b", "ER"}, {"b", "c", "ER"}, {"d", "b", "ER"}})
+	b := network.Nodes["b"]
+	if b.OutDegree() != 1 || b.InDegree() != 2 {
+		t.Errorf("Node b: got out/in degrees %d/%d, expected 1/2", b.OutDegree(), b.InDegree())
+	}
+	// Traversals follow the direction of the edges
+	for start, expected := range map[string]int{"a": 3, "b": 2, "c": 1, "d": 3} {
+		subN, isSub := DetectSubs(network.Nodes[start], 10)
+		if !isSub || len(subN) != expected {
+			t.Errorf("DetectSubs from %s: got %d nodes (%t), expected %d", start, len(subN), isSub, expected)
+		}
+		if subNV, _ := DetectSubsVertical(network.Nodes[start], 10); len(subNV) != expected {
+			t.Errorf("DetectSubsVertical from %s: got %d nodes, expected %d", start, len(subNV), expected)
+		}
+	}
+	A, LUT := network.GetAMatrix()
+	if isMat64Symmetric(A) {
+		t.Error("The adjacency matrix of a directed network should not be symmetric")
+	}
+	if A.At(LUT.ilut[network.Nodes["a"]], LUT.ilut[b]) != 1 || A.At(LUT.ilut[b], LUT.ilut[network.Nodes["a"]]) != 0 {
+		t.Error("The adjacency matrix doesn't follow the direction of the edges")
+	}
+	// The random walkers get stuck in c if they don't restart
+	pi := network.PageRankRW(2, 1e4, nil)
+	if pi[network.Nodes["c"]] <= pi[network.Nodes["a"]] {
+		t.Errorf("PageRankRW: node c (%v) should rank higher than node a (%v)", pi[network.Nodes["c"]], pi[network.Nodes["a"]])
+	}
+	// Symmetrical networks see every edge from both ends
+	network = newTestNetwork("TestSymmetrical", [][3]string{{"a", "b", "ER"}, {"b", "c", "ER"}, {"d", "b", "ER"}})
+	if b := network.Nodes["b"]; b.OutDegree() != 3 || b.InDegree() != 3 {
+		t.Errorf("Node b: got out/in degrees %d/%d, expected 3/3", b.OutDegree(), b.InDegree())
+	}
+	if subN, _ := DetectSubs(network.Nodes["c"], 10); len(subN) != 4 {
+		t.Errorf("DetectSubs from c: got %d nodes, expected 4", len(subN))
+	}
+}
+
+func TestCcrDetectSubsVertical(t *testing.T) {
+	fmt.Println("### TESTING the concurrent depth-first detection")
+	// A star with short branches, and a long one
+	edges := [][3]string{}
+	for _, leaf := range []string{"l1", "l2", "l3", "l4", "l5"} {
+		edges = append(edges, [3]string{"center", leaf, "EE"})
+	}
+	for i, previous := 0, "center"; i < 10; i++ {
+		node := fmt.Sprint("arm", i)
+		edges = append(edges, [3]string{previous, node, "EE"})
+		previous = node
+	}
+	network := newTestNetwork("TestCcrDetectSubsVertical", edges)
+	if subN, isSub := CcrDetectSubsVertical(network.Nodes["center"], 4); isSub {
+		t.Errorf("Got a complete subnetwork of %d nodes, expected the long branch to be cut", len(subN))
+	}
+	if subN, isSub := CcrDetectSubsVertical(network.Nodes["center"], 20); !isSub || len(subN) != network.Nnodes {
+		t.Errorf("Got %d nodes (complete: %t), expected the %d nodes", len(subN), isSub, network.Nnodes)
+	}
+}
+
+func checkAdjacency(t *testing.T, network *Network) {
+	nEntries := 0
+	for _, node := range network.Nodes {
+		for _, ens := range [][]*EdgeToNode{node.Edges, node.InEdges} {
+			for _, en := range ens {
+				if network.Edges[en.Edge.Name] != en.Edge {
+					t.Errorf("Node %s still refers to the removed edge %s", node.Name, en.Edge.Name)
+				}
+				if network.Nodes[en.ToNode.Name] != en.ToNode {
+					t.Errorf("Node %s still refers to the removed node %s", node.Name, en.ToNode.Name)
+				}
+				nEntries++
+			}
+		}
+	}
+	if len(network.Nodes) != network.Nnodes || len(network.Edges) != network.Nedges {
+		t.Errorf("Counts are off: %d/%d nodes and %d/%d edges", len(network.Nodes), network.Nnodes, len(network.Edges), network.Nedges)
+	}
+	expected := 2 * network.Nedges
+	if network.Symmetrical {
+		expected = 4 * network.Nedges
+	}
+	if nEntries != expected {
+		t.Errorf("Got %d adjacency entries, expected %d", nEntries, expected)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	fmt.Println("### TESTING the removal of nodes and edges")
+	edges := [][3]string{{"a", "b", "ER"}, {"b", "c", "ER"}, {"d", "b", "ER"}, {"c", "d", "EE"}, {"a", "e", "ER"}}
+	for _, symmetrical := range []bool{true, false} {
+		network := NewNetwork("TestRemove", ioutil.Discard, testFolder)
+		network.Symmetrical = symmetrical
+		addTestEdges(&network, edges)
+		if !network.RemoveEdge("c_d") || network.RemoveEdge("c_d") {
+			t.Error("RemoveEdge should succeed once only")
+		}
+		checkAdjacency(t, &network)
+		if !network.RemoveNode("b") || network.Nedges != 1 || network.Nnodes != 4 {
+			t.Errorf("RemoveNode: got %d nodes and %d edges, expected 4 and 1", network.Nnodes, network.Nedges)
+		}
+		checkAdjacency(t, &network)
+		if n := network.RemoveEdges(func(e *Edge) bool { return e.Kind == ER }); n != 1 {
+			t.Errorf("RemoveEdges: removed %d edges, expected 1", n)
+		}
+		if n := network.RemoveNodes(func(n *Node) bool { return len(n.Edges) == 0 }); n != 4 {
+			t.Errorf("RemoveNodes: removed %d nodes, expected 4", n)
+		}
+		checkAdjacency(t, &network)
+	}
+}
@@ -1,12 +1,17 @@
 package go_nets
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kr/pretty"
@@ -34,18 +39,38 @@ type AttrVersionContainer struct {
 	Attr string `xml:"Version,attr"`
 }
 
+type AttrActionContainer struct {
+	Attr string `xml:"Action,attr"`
+}
+
 type Filing struct {
-	XMLName            xml.Name            `xml:"FileDetail"`
-	Method             AttrMethodContainer `xml:"FilingMethod"`
-	Amendment          AttrTypeContainer   `xml:"AmendmentType"`
-	FilingType         AttrTypeContainer   `xml:"TransType"`
-	AltFilingType      AttrTypeContainer   `xml:"AltFilingType"`
+	XMLName            xml.Name              `xml:"FileDetail" json:"-"`
+	Method             AttrMethodContainer   `xml:"FilingMethod" json:"FilingMethod"`
+	Amendment          AttrTypeContainer     `xml:"AmendmentType" json:"AmendmentType"`
+	AmendmentTypes     []AttrTypeContainer   `xml:"AmendmentTypeLoop>AmendmentType" json:"AmendmentTypeLoop"`
+	AmendmentActions   []AttrActionContainer `xml:"AmendmentActionLoop>AmendmentAction" json:"AmendmentActionLoop"`
+	FilingType         AttrTypeContainer     `xml:"TransType" json:"TransType"`
+	AltFilingType      AttrTypeContainer     `xml:"AltFilingType" json:"AltFilingType"`
 	OriginalFileNumber int
 	FileNumber         int
 	OriginalFileDate   string
 	FileDate           string
-	Debtors            []Agent `xml:"Debtors>DebtorName>Names"`
-	Securers           []Agent `xml:"Secured>Names"`
+	LapseDate          string
+	Debtors            []Agent      `xml:"Debtors>DebtorName>Names" json:"Debtors"`
+	Securers           []Agent      `xml:"Secured>Names" json:"Secured"`
+	Source             string       `xml:"-" json:"-"` // File the filing has been parsed from, set by the parsers
+	Record             int          `xml:"-" json:"-"` // Index of the filing in this file
+	Mode               DispatchMode `xml:"-" json:"-"` // How the filing is dispatched in the networks, CliqueDispatch by default
+}
+
+//SetSource implements Sourced.
+func (f *Filing) SetSource(source string, record int) {
+	f.Source, f.Record = source, record
+}
+
+//Checkpoint implements Checkpointed, for the filings that have been parsed from a file.
+func (f Filing) Checkpoint() (string, int) {
+	return f.Source, f.Record
 }
 
 func DeleteAgent(agents []Agent, ind int) []Agent {
@@ -61,6 +86,15 @@ func DeleteAgent(agents []Agent, ind int) []Agent {
 	return agents
 }
 
+//Clean implements Cleaner: the filings without at least 2 parties are discarded.
+func (f *Filing) Clean() error {
+	f.clean()
+	if n := len(f.Debtors) + len(f.Securers); n < 2 {
+		return fmt.Errorf("Record %d as been discarded because less than 2 debtor/secured party found.", f.OriginalFileNumber)
+	}
+	return nil
+}
+
 func (f *Filing) clean() {
 	// NullAgent := Agent{}
 	i := 0
@@ -97,7 +131,8 @@ type Agent struct {
 	State            string
 	PostalCode       string
 	// County           string
-	Country string
+	Country    string
+	FileNumber int `xml:"-" json:"-"` // Filing the agent has been found in, set when dispatching
 }
 
 type IndividualName struct {
@@ -110,80 +145,387 @@ type XmlParser struct {
 	FileDir  string
 	FileName string
 	Encoding encoding.Encoding
+	OnError  func(error) // Called for each failed record (can be nil)
+	Skip     int         // Number of records to skip, already saved by a previous run
 }
 
-func (p *XmlParser) Parse(c chan Filing, logDst io.Writer) {
+//Shared machinery of the parsers --
 
-	// Unpack arguments & Initialize
-	// Logger
+func newParserLogger(logDst io.Writer) *log.Logger {
 	if logDst == nil {
 		logDst = os.Stdout
 	}
-	logger := log.New(logDst, "parserLog", log.Lshortfile)
+	return log.New(logDst, "parserLog", log.Lshortfile)
+}
 
-	// Open input file and defer closing
-	// var fi *os.File //non necessary if errors with different names
-	fi, errOs := os.Open(p.FileDir + p.FileName)
+//openEncoded opens the file, and transforms its encoding to UTF-8 when an encoding is given.
+func openEncoded(fileDir, fileName string, enc encoding.Encoding) (*os.File, io.Reader, error) {
+	fi, errOs := os.Open(fileDir + fileName)
 	if errOs != nil {
-		panic(errOs)
+		return nil, nil, errOs
+	}
+	if enc != nil {
+		return fi, transform.NewReader(fi, enc.NewDecoder()), nil
+	}
+	return fi, fi, nil
+}
+
+//ParseReport sums up the parsing of a file.
+type ParseReport struct {
+	FileName  string
+	Parsed    int     // Records sent over the channel
+	Discarded int     // Records rejected by their Clean method
+	Failed    int     // Records that could not be decoded
+	Skipped   int     // Records skipped when resuming
+	Errors    []error // Errors of the failed records
+}
+
+func (r ParseReport) String() string {
+	return fmt.Sprintf("%s: %d records parsed, %d discarded, %d failed, %d skipped", r.FileName, r.Parsed, r.Discarded, r.Failed, r.Skipped)
+}
+
+//RecordError is the error of a record that could not be decoded. The parsing goes on with the next record.
+type RecordError struct {
+	FileName string
+	Record   int   // Index of the record in the file, starting at 1
+	Offset   int64 // Offset of the record in the (decoded) file. Line number for the JSON Lines.
+	Err      error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("PARSE_ERROR: record %d (offset %d) of file %s: %s", e.Record, e.Offset, e.FileName, e.Err)
+}
+
+func (r *ParseReport) fail(err *RecordError, logger *log.Logger, onError func(error)) {
+	r.Failed++
+	r.Errors = append(r.Errors, err)
+	logger.Println(err)
+	if onError != nil {
+		onError(err)
+	}
+}
+
+//sendFiling cleans the filing and sends it over the channel, unless it has less than 2 parties.
+//It fails with the error of the context when the context is cancelled before the filing can be sent.
+func sendFiling(ctx context.Context, c chan Filing, p Filing, logger *log.Logger) (bool, error) {
+	if err := p.Clean(); err != nil {
+		logger.Println(err)
+		return false, nil
+	}
+	select {
+	case c <- p:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+//FilingParser is implemented by all the parsers of Filings.
+//The channel is always closed at the end, the error is only returned when the whole file could not be parsed.
+//ParseContext stops (returning the error of the context) when the context is cancelled.
+type FilingParser interface {
+	Parse(c chan Filing, logDst io.Writer) (ParseReport, error)
+	ParseContext(ctx context.Context, c chan Filing, logDst io.Writer) (ParseReport, error)
+}
+
+//NewFilingParser chooses the parser from the extension of the file: JSON Lines for .json, .jsonl and .ndjson, XML otherwise.
+func NewFilingParser(fileDir, fileName string, enc encoding.Encoding) FilingParser {
+	return ResumeFilingParser(fileDir, fileName, enc, 0)
+}
+
+//ResumeFilingParser is NewFilingParser for a file whose first records have already been saved (see SqlSaver.Checkpoints).
+func ResumeFilingParser(fileDir, fileName string, enc encoding.Encoding, skip int) FilingParser {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json", ".jsonl", ".ndjson":
+		return &JSONParser{fileDir, fileName, enc, nil, skip}
+	}
+	return &XmlParser{fileDir, fileName, enc, nil, skip}
+}
+
+//Generic parsing of records --
+
+//Record is what the generic parsers produce: it can be added to a network and saved.
+type Record interface {
+	Dispatcher
+	Saveable
+}
+
+//Cleaner is implemented by the Records that need to be cleaned once parsed.
+//Clean returns an error when the record has to be discarded.
+type Cleaner interface {
+	Clean() error
+}
+
+func clean(r interface{}) error {
+	if cleaner, ok := r.(Cleaner); ok {
+		return cleaner.Clean()
+	}
+	return nil
+}
+
+//Sourced is implemented by the Records that keep track of where they have been parsed from.
+//The parsers call SetSource with the name of the file and the index of the record, starting at 1.
+type Sourced interface {
+	SetSource(source string, record int)
+}
+
+//Same contract as the FilingParser.
+type Parser interface {
+	Parse(c chan Record, logDst io.Writer) (ParseReport, error)
+	ParseContext(ctx context.Context, c chan Record, logDst io.Writer) (ParseReport, error)
+}
+
+//XmlRecordParser streams the elements of name Element out of a xml file, decoding each of them
+//into a new Record. A corrupt element only loses this record: the parser goes on from the next element.
+type XmlRecordParser struct {
+	FileDir  string
+	FileName string
+	Encoding encoding.Encoding
+	Element  string
+	New      func() Record // Returns a pointer to decode the element into
+	OnError  func(error)   // Called for each failed record (can be nil)
+	Skip     int           // Number of records to skip, already saved by a previous run
+}
+
+func (p *XmlRecordParser) Parse(c chan Record, logDst io.Writer) (ParseReport, error) {
+	return p.ParseContext(context.Background(), c, logDst)
+}
+
+func (p *XmlRecordParser) ParseContext(ctx context.Context, c chan Record, logDst io.Writer) (report ParseReport, err error) {
+	defer close(c)
+	report.FileName = p.FileName
+
+	// Unpack arguments & Initialize
+	// Logger
+	logger := newParserLogger(logDst)
+
+	// Open input file (transforming the encoding of the reading pipe) and defer closing
+	fi, fiUTF8, err := openEncoded(p.FileDir, p.FileName, p.Encoding)
+	if err != nil {
+		logger.Println("PARSE_ERROR:", err)
+		return report, err
 	}
 	logger.Println("Opening file: ", p.FileName)
 	defer func() {
-		if errOs = fi.Close(); errOs != nil {
-			panic(errOs)
+		if errOs := fi.Close(); errOs != nil && err == nil {
+			err = errOs
 		}
 	}()
-	// Transform the encoding of the reading pipe
-	var fiUTF8 = io.Reader(fi)
-	if enc := p.Encoding; enc != nil {
-		fiUTF8 = transform.NewReader(fi, enc.NewDecoder())
-	} else {
-		fiUTF8 = fi
-	}
-	// Parse the xml
-	decoder := xml.NewDecoder(fiUTF8)
+	// Cut the elements out of the stream, and decode them one at a time
+	scanner := newElementScanner(fiUTF8, p.Element)
 	i := 0
 	t0 := time.Now()
 	for {
-		// Read tokens from the XML document in a stream.
-		t, err := decoder.Token()
-		if t == nil {
-			if err != io.EOF {
-				fmt.Println("Error for file " + p.FileName + "...")
-				log.Println(err)
-				// err = decoder.Skip() // Doesn't work, will bump into the same error again.
-				// if err == nil {
-				// 	fmt.Println("Skipping token...")
-				// 	continue
-				// }
-				// log.Println(err)
-			}
-			close(c)
-			break
+		if err = ctx.Err(); err != nil {
+			logger.Println("PARSE_INTERRUPTED:", err)
+			return report, err
 		}
-		// Inspect the type of the token just read.
-		switch se := t.(type) {
-		case xml.StartElement:
-			// If we just read a StartElement token
-			// ...and its name is "FileDetail"
-			if se.Name.Local == "FileDetail" {
-				var p Filing
-				// decode a whole chunk of following XML into the
-				// variable p which is a Filing (see above)
-				decoder.DecodeElement(&p, &se)
-				p.clean()
-				// Check and Send the element
-				if n := len(p.Debtors) + len(p.Securers); n > 1 {
-					c <- p
-				} else {
-					logger.Printf("Record %d as been discarded because less than 2 debtor/secured party found.\n", p.OriginalFileNumber)
+		element, offset, errRecord, errRead := scanner.next()
+		if element != nil {
+			i++
+			if i <= p.Skip {
+				report.Skipped++
+				continue
+			}
+			r := p.New()
+			if errRecord == nil {
+				errRecord = xml.Unmarshal(element, r)
+			}
+			if sourced, ok := r.(Sourced); ok {
+				sourced.SetSource(p.FileName, i)
+			}
+			// Check and Send the element
+			if errRecord != nil {
+				report.fail(&RecordError{p.FileName, i, offset, errRecord}, logger, p.OnError)
+			} else if errClean := clean(r); errClean != nil {
+				logger.Println(errClean)
+				report.Discarded++
+			} else {
+				select {
+				case c <- r:
+					report.Parsed++
+				case <-ctx.Done():
+					logger.Println("PARSE_INTERRUPTED:", ctx.Err())
+					return report, ctx.Err()
 				}
-				i++
 			}
 		}
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			fmt.Println("Error for file " + p.FileName + "...")
+			logger.Println("PARSE_ERROR:", errRead)
+			return report, errRead
+		}
 	}
 	t1 := time.Now()
-	fmt.Printf("\n Successfully parsed %d filings in %v from file %s. \n", i, t1.Sub(t0), p.FileName)
+	fmt.Printf("\n Successfully parsed %d records in %v from file %s (%d discarded, %d failed). \n", i, t1.Sub(t0), p.FileName, report.Discarded, report.Failed)
+	return report, nil
+}
+
+//elementScanner cuts the elements of a given name out of a xml stream, without decoding them.
+type elementScanner struct {
+	r       *bufio.Reader
+	name    string
+	offset  int64
+	started bool // The '<' of the next element has already been read
+}
+
+func newElementScanner(r io.Reader, name string) *elementScanner {
+	return &elementScanner{bufio.NewReader(r), name, 0, false}
+}
+
+//readSlice reads up to the delimiter (included), or what the buffer can hold.
+func (s *elementScanner) readSlice(delim byte) ([]byte, error) {
+	b, err := s.r.ReadSlice(delim)
+	s.offset += int64(len(b))
+	if err == bufio.ErrBufferFull { // The caller checks the last byte
+		err = nil
+	}
+	return b, err
+}
+
+//isTag tells if what follows a '<' is the given tag name.
+func (s *elementScanner) isTag(tag string) bool {
+	b, _ := s.r.Peek(len(tag) + 1)
+	if len(b) < len(tag)+1 || string(b[:len(tag)]) != tag {
+		return false
+	}
+	return strings.IndexByte(" \t\r\n/>", b[len(tag)]) >= 0
+}
+
+//readTag reads up to the end of the current tag, the '>' in the values of its attributes included.
+func (s *elementScanner) readTag(element []byte) ([]byte, error) {
+	var quote byte // Quote of the attribute value being read, 0 outside of the values
+	for {
+		b, err := s.readSlice('>')
+		element = append(element, b...)
+		if err != nil {
+			return element, err
+		}
+		for _, c := range b {
+			if quote == 0 && (c == '"' || c == '\'') {
+				quote = c
+			} else if c == quote {
+				quote = 0
+			}
+		}
+		if quote == 0 && b[len(b)-1] == '>' {
+			return element, nil
+		}
+	}
+}
+
+//The comments and CDATA sections are not parsed: the tags in them are text.
+var unparsedSections = [][2]string{{"!--", "-->"}, {"![CDATA[", "]]>"}}
+
+//readUnparsed reads up to the end of the comment or CDATA section, when the '<' just read opens one.
+func (s *elementScanner) readUnparsed(element []byte) ([]byte, bool, error) {
+	for _, section := range unparsedSections {
+		if b, _ := s.r.Peek(len(section[0])); string(b) != section[0] {
+			continue
+		}
+		start := len(element)
+		for {
+			b, err := s.readSlice('>')
+			element = append(element, b...)
+			if err != nil {
+				return element, true, err
+			}
+			if read := element[start:]; len(read) >= len(section[0])+len(section[1]) && bytes.HasSuffix(read, []byte(section[1])) {
+				return element, true, nil
+			}
+		}
+	}
+	return element, false, nil
+}
+
+var errUnclosedElement = fmt.Errorf("element not closed before the next one")
+
+//next returns the next element and its offset. errRecord is set when the element is corrupt, errRead
+//when the stream cannot be read anymore (io.EOF at the end).
+func (s *elementScanner) next() (element []byte, offset int64, errRecord, errRead error) {
+	// Skip to the start tag
+	for !s.started {
+		b, err := s.readSlice('<')
+		if err != nil {
+			return nil, s.offset, nil, err
+		}
+		if b[len(b)-1] != '<' {
+			continue
+		}
+		if _, unparsed, err := s.readUnparsed(nil); err != nil {
+			return nil, s.offset, nil, err
+		} else if !unparsed {
+			s.started = s.isTag(s.name)
+		}
+	}
+	s.started = false
+	offset = s.offset - 1
+	element, err := s.readTag([]byte{'<'})
+	if err != nil {
+		return element, offset, io.ErrUnexpectedEOF, err
+	}
+	if element[len(element)-2] == '/' { // Empty element
+		return element, offset, nil, nil
+	}
+	// Read up to the end tag
+	for {
+		b, err := s.readSlice('<')
+		element = append(element, b...)
+		if err != nil {
+			return element, offset, io.ErrUnexpectedEOF, err
+		}
+		if element[len(element)-1] != '<' {
+			continue
+		}
+		var unparsed bool
+		if element, unparsed, err = s.readUnparsed(element); err != nil {
+			return element, offset, io.ErrUnexpectedEOF, err
+		} else if unparsed {
+			continue
+		}
+		if s.isTag(s.name) { // The next element starts before the end of this one: leave it for the next call
+			s.started = true
+			return element[:len(element)-1], offset, errUnclosedElement, nil
+		}
+		if s.isTag("/" + s.name) {
+			element, err = s.readTag(element)
+			if err != nil {
+				return element, offset, io.ErrUnexpectedEOF, err
+			}
+			return element, offset, nil, nil
+		}
+	}
+}
+
+//The Filings parser is the generic one, on the FileDetail elements.
+func (p *XmlParser) Parse(c chan Filing, logDst io.Writer) (ParseReport, error) {
+	return p.ParseContext(context.Background(), c, logDst)
+}
+
+func (p *XmlParser) ParseContext(ctx context.Context, c chan Filing, logDst io.Writer) (ParseReport, error) {
+	rc := make(chan Record)
+	rp := XmlRecordParser{p.FileDir, p.FileName, p.Encoding, "FileDetail", func() Record { return &Filing{} }, p.OnError, p.Skip}
+	var (
+		report ParseReport
+		err    error
+	)
+	done := make(chan bool)
+	go func() {
+		report, err = rp.ParseContext(ctx, rc, logDst)
+		done <- true
+	}()
+	for r := range rc {
+		select {
+		case c <- *r.(*Filing):
+		case <-ctx.Done(): // Drop it, the record parser stops too
+		}
+	}
+	close(c)
+	<-done
+	return report, err
 }
 
 type OnOffWriter struct {
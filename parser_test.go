@@ -1,8 +1,15 @@
 package go_nets
 
 import (
+	"context"
+	"database/sql"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
+	"os"
+	"strings"
 	"testing"
 
 	"code.google.com/p/go.text/encoding/charmap"
@@ -56,3 +63,208 @@ func TestClean(t *testing.T) {
 	pretty.Printf("Seeing: %# v\n and len(Debtors) = %d \n", f, len(f.Debtors))
 
 }
+
+// A record from another feed, for testing the generic parser
+type testOfficer struct {
+	Company string
+	Name    string
+	Role    string `xml:"role,attr"`
+}
+
+func (o *testOfficer) Clean() error {
+	if o.Company == "" || o.Name == "" {
+		return fmt.Errorf("Officer %q of %q has been discarded", o.Name, o.Company)
+	}
+	return nil
+}
+
+func (o *testOfficer) Dispatch(logger *log.Logger) ([]Noder, []Edger) {
+	company := &SimpleNoder{Name: Atomize(o.Company), Kind: Emitter}
+	officer := &SimpleNoder{Name: strings.ToLower(o.Name), Kind: Receiver, Data: Attributes{"role": o.Role}}
+	return []Noder{company, officer}, []Edger{&SimpleEdger{Name: company.Name + "_" + officer.Name, Kind: ER, SrcId: company.Name, DstId: officer.Name}}
+}
+
+func (o *testOfficer) GetInitStatements() []string {
+	return []string{"CREATE TABLE IF NOT EXISTS officers (company TEXT, name TEXT, role TEXT)"}
+}
+
+func (o *testOfficer) GetSavingStatements() []Statement {
+	return []Statement{{"INSERT INTO officers VALUES (?, ?, ?)", []interface{}{o.Company, o.Name, o.Role}}}
+}
+
+func TestXmlRecordParser(t *testing.T) {
+	fmt.Println("### TESTING the generic parser")
+	os.Mkdir(testFolder, os.FileMode(0777))
+	data := `<Officers>
+  <Officer role="CEO"><Company>ACME, INC.</Company><Name>John Doe</Name></Officer>
+  <Officer role="CFO"><Company>ACME, INC.</Company><Name>Jane Doe</Name></Officer>
+  <Officer role="CTO"><Company>ACME, INC.</Company></Officer>
+</Officers>`
+	if err := ioutil.WriteFile(testFolder+"TestRecords.xml", []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var parser Parser = &XmlRecordParser{testFolder, "TestRecords.xml", nil, "Officer", func() Record { return &testOfficer{} }, nil, 0}
+	// Into a network
+	cs := make(chan Record)
+	go parser.Parse(cs, ioutil.Discard)
+	network := NewNetwork("TestRecords", ioutil.Discard, testFolder)
+	i := 0
+	for r := range cs {
+		network.AddDispatcher(r)
+		i++
+	}
+	if i != 2 || network.Nnodes != 3 || network.Nedges != 2 {
+		t.Errorf("Got %d records, %d nodes and %d edges, expected 2, 3 and 2", i, network.Nnodes, network.Nedges)
+	}
+	if role, _ := GetString(network.Nodes["jane doe"].NodeData, "role"); role != "CFO" {
+		t.Errorf("Got role %q, expected CFO", role)
+	}
+	// Into a database
+	cs = make(chan Record)
+	go parser.Parse(cs, ioutil.Discard)
+	ListenAndSave(RecordToSaveable(cs), &SqlSaver{DbPath: testFolder, DbName: "TestRecords", DBDriver: "sqlite3"})
+	db, err := sql.Open("sqlite3", testFolder+"TestRecords.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow("SELECT COUNT(*) FROM officers").Scan(&n); err != nil || n != 2 {
+		t.Errorf("Got %d officers saved (%v), expected 2", n, err)
+	}
+}
+
+func TestXmlParserSample(t *testing.T) {
+	fmt.Println("### TESTING the parser (sample filing)")
+	os.Mkdir(testFolder, os.FileMode(0777))
+	data := "<Results>" + testFileDetail + "<FileDetail><FileNumber>1</FileNumber></FileDetail>" + testFileDetail + "</Results>"
+	if err := ioutil.WriteFile(testFolder+"TestSample.xml", []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cs := make(chan Filing)
+	go NewFilingParser(testFolder, "TestSample.xml", nil).Parse(cs, ioutil.Discard)
+	i := 0
+	for f := range cs {
+		if f.FileNumber != 137363375544 || len(f.Debtors) != 1 || len(f.Securers) != 1 {
+			t.Errorf("Unexpected filing %+v", f)
+		}
+		i++
+	}
+	if i != 2 {
+		t.Errorf("Got %d filings, expected 2", i)
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	fmt.Println("### TESTING the recovery of the parser after corrupt records")
+	os.Mkdir(testFolder, os.FileMode(0777))
+	good := func(n int) string {
+		return fmt.Sprintf("<FileDetail><FileNumber>%d</FileNumber><Secured><Names><OrganizationName>BANK</OrganizationName></Names></Secured>"+
+			"<Debtors><DebtorName><Names><OrganizationName>SHOP %d</OrganizationName></Names></DebtorName></Debtors></FileDetail>\n", n, n)
+	}
+	data := "<?xml version=\"1.0\"?>\n<Results>\n" +
+		good(1) +
+		"<FileDetail><FileNumber>2</FileNum></FileDetail>\n" + // Mismatched tags
+		"<FileDetail><FileNumber>3</FileNumber><Debtors>\n" + // Never closed
+		good(4) +
+		"<FileDetail><FileNumber>5 & 6</FileNumber></FileDetail>\n" + // Bad entity
+		"<FileDetail><FileNumber>7</FileNumber></FileDetail>\n" + // Discarded, no parties
+		"<FileDetail/>\n" + // Discarded too
+		good(8) +
+		"</Results>\n<FileDetail><FileNumber>9</FileNumber>" // Truncated
+	if err := ioutil.WriteFile(testFolder+"TestRecovery.xml", []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	onError := 0
+	parser := XmlParser{testFolder, "TestRecovery.xml", nil, func(err error) { onError++ }, 0}
+	cs := make(chan Filing)
+	numbers := []int{}
+	go func() {
+		for f := range cs {
+			numbers = append(numbers, f.FileNumber)
+		}
+	}()
+	report, err := parser.Parse(cs, ioutil.Discard)
+	fmt.Println(report)
+	if err != nil {
+		t.Error(err)
+	}
+	if report.Parsed != 3 || report.Discarded != 2 || report.Failed != 4 || onError != 4 || len(report.Errors) != 4 {
+		t.Errorf("Got %s (%d errors reported), expected 3 parsed, 2 discarded, 4 failed", report, onError)
+	}
+	for i, n := range []int{2, 3, 5, 9} {
+		if i < len(report.Errors) {
+			if re, ok := report.Errors[i].(*RecordError); !ok || !strings.Contains(data[re.Offset:], fmt.Sprintf("<FileNumber>%d", n)) {
+				t.Errorf("Error %d should be for filing %d, got %v", i, n, report.Errors[i])
+			}
+		}
+	}
+	// Missing file
+	cs = make(chan Filing)
+	go func() {
+		for range cs {
+		}
+	}()
+	if _, err = (&XmlParser{FileDir: testFolder, FileName: "missing.xml"}).Parse(cs, ioutil.Discard); err == nil {
+		t.Error("Parsing a missing file should fail")
+	}
+}
+
+func TestElementScanner(t *testing.T) {
+	fmt.Println("### TESTING the scanner of the elements")
+	elements := []string{
+		`<Item note="a > b" other='c > d'><Value>1</Value></Item>`,      // '>' in the values of the attributes
+		`<Item><Value><![CDATA[</Item><Item>]]></Value></Item>`,         // Tags in a CDATA section
+		`<Item><!-- </Item> <Item> --><Value>3</Value></Item>`,          // Tags in a comment
+		`<Item><!----><Value><![CDATA[]]></Value><Value a="x"/></Item>`, // Empty sections
+	}
+	data := "<?xml version=\"1.0\"?>\n<!-- <Item>Commented out</Item> -->\n<Items><![CDATA[<Item>]]>\n" +
+		strings.Join(elements, "\n") + "\n</Items>\n"
+	scanner := newElementScanner(strings.NewReader(data), "Item")
+	for i := 0; ; i++ {
+		element, offset, errRecord, errRead := scanner.next()
+		if errRead == io.EOF {
+			if i != len(elements) {
+				t.Errorf("Got %d elements, expected %d", i, len(elements))
+			}
+			break
+		}
+		if errRecord != nil || errRead != nil {
+			t.Fatalf("Element %d: got errors %v and %v", i, errRecord, errRead)
+		}
+		if i >= len(elements) || string(element) != elements[i] || data[offset:offset+int64(len(element))] != elements[i] {
+			t.Errorf("Element %d: got %q at offset %d", i, element, offset)
+			continue
+		}
+		var item struct {
+			Values []string `xml:"Value"`
+		}
+		if err := xml.Unmarshal(element, &item); err != nil {
+			t.Errorf("Element %d: %v", i, err)
+		}
+	}
+}
+
+func TestParserCancel(t *testing.T) {
+	fmt.Println("### TESTING the cancellation of the parser")
+	os.Mkdir(testFolder, os.FileMode(0777))
+	data := "<Results>" + strings.Repeat(testFileDetail, 10) + "</Results>"
+	if err := ioutil.WriteFile(testFolder+"TestCancel.xml", []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cs := make(chan Filing)
+	errCh := make(chan error)
+	go func() {
+		_, err := NewFilingParser(testFolder, "TestCancel.xml", nil).ParseContext(ctx, cs, ioutil.Discard)
+		errCh <- err
+	}()
+	<-cs
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Errorf("Got error %v, expected %v", err, context.Canceled)
+	}
+	if _, ok := <-cs; ok {
+		t.Error("The channel should be closed once the parser is cancelled")
+	}
+}
@@ -0,0 +1,285 @@
+package go_nets
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+//Paths between nodes --
+//The paths follow the edges of the nodes (Node.Edges): both ways in a symmetrical network, from the source
+//to the destination of the edges otherwise. Each hop keeps its edge, and so the data of its link (e.g. the filing).
+
+var ErrNoPath = errors.New("PATH ERROR: no path between the nodes")
+
+//Path is a sequence of hops, Edges[i] linking Nodes[i] to Nodes[i+1].
+type Path struct {
+	Nodes []*Node
+	Edges []*Edge
+	Cost  float64 // Number of hops, or sum of the costs of the edges for the weighted paths
+}
+
+//Hop is a step of a path, from one node to the next through the edge.
+type Hop struct {
+	From, To *Node
+	*Edge
+}
+
+func (p Path) Len() int {
+	return len(p.Edges)
+}
+
+func (p Path) Hops() []Hop {
+	hops := make([]Hop, len(p.Edges))
+	for i, e := range p.Edges {
+		hops[i] = Hop{p.Nodes[i], p.Nodes[i+1], e}
+	}
+	return hops
+}
+
+func (p Path) String() string {
+	if len(p.Nodes) == 0 {
+		return "<no path>"
+	}
+	s := []string{p.Nodes[0].Name}
+	for _, h := range p.Hops() {
+		s = append(s, fmt.Sprintf("-[%s]-> %s", h.Edge.Name, h.To.Name))
+	}
+	return strings.Join(s, " ")
+}
+
+//EdgeCost gives the cost of going through an edge, for the weighted paths. It must not be negative,
+//an infinite cost making the edge impassable.
+type EdgeCost func(*Edge) float64
+
+var (
+	UnitCost      EdgeCost = func(e *Edge) float64 { return 1 }
+	InverseWeight EdgeCost = func(e *Edge) float64 { // The heavier the edge, the closer its nodes. The edges without weight are impassable
+		if e.Weight == 0 {
+			return math.Inf(1)
+		}
+		return 1 / float64(e.Weight)
+	}
+)
+
+func (n *Network) endsOf(from, to string) (*Node, *Node, error) {
+	src, ok := n.Nodes[from]
+	if !ok {
+		return nil, nil, fmt.Errorf("PATH ERROR: no node %q in network %q", from, n.Name)
+	}
+	dst, ok := n.Nodes[to]
+	if !ok {
+		return nil, nil, fmt.Errorf("PATH ERROR: no node %q in network %q", to, n.Name)
+	}
+	return src, dst, nil
+}
+
+//ShortestPath returns a path with the fewest hops between the nodes, found breadth-first.
+func (n *Network) ShortestPath(from, to string) (Path, error) {
+	src, dst, err := n.endsOf(from, to)
+	if err != nil {
+		return Path{}, err
+	}
+	previous := map[*Node]*EdgeToNode{src: nil}
+	queue := []*Node{src}
+	for len(queue) > 0 && previous[dst] == nil && src != dst {
+		node := queue[0]
+		queue = queue[1:]
+		for _, en := range node.Edges {
+			if _, seen := previous[en.ToNode]; !seen {
+				previous[en.ToNode] = en
+				queue = append(queue, en.ToNode)
+			}
+		}
+	}
+	if _, found := previous[dst]; !found {
+		return Path{}, ErrNoPath
+	}
+	p := tracePath(src, dst, previous)
+	p.Cost = float64(p.Len())
+	return p, nil
+}
+
+//tracePath builds the path from the edges leading to each node.
+func tracePath(src, dst *Node, previous map[*Node]*EdgeToNode) Path {
+	p := Path{Nodes: []*Node{dst}}
+	for node := dst; node != src; {
+		en := previous[node]
+		node = en.Edge.Src
+		if en.ToNode == en.Edge.Src { // Edge taken backwards, in a symmetrical network
+			node = en.Edge.Dst
+		}
+		p.Nodes = append(p.Nodes, node)
+		p.Edges = append(p.Edges, en.Edge)
+	}
+	for i, j := 0, len(p.Nodes)-1; i < j; i, j = i+1, j-1 {
+		p.Nodes[i], p.Nodes[j] = p.Nodes[j], p.Nodes[i]
+	}
+	for i, j := 0, len(p.Edges)-1; i < j; i, j = i+1, j-1 {
+		p.Edges[i], p.Edges[j] = p.Edges[j], p.Edges[i]
+	}
+	return p
+}
+
+//WeightedShortestPath returns the path of least cost between the nodes, with Dijkstra's algorithm.
+//A nil cost is the InverseWeight.
+func (n *Network) WeightedShortestPath(from, to string, cost EdgeCost) (Path, error) {
+	src, dst, err := n.endsOf(from, to)
+	if err != nil {
+		return Path{}, err
+	}
+	if cost == nil {
+		cost = InverseWeight
+	}
+	return dijkstra(src, dst, cost, nil, nil)
+}
+
+//Priority queue of the nodes to visit, the first pushed first among the same distances.
+type pathItem struct {
+	node     *Node
+	distance float64
+	order    int
+}
+
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool {
+	return q[i].distance < q[j].distance || q[i].distance == q[j].distance && q[i].order < q[j].order
+}
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+//dijkstra finds the path of least cost, without going through the removed edges and nodes, nor the edges of infinite cost.
+func dijkstra(src, dst *Node, cost EdgeCost, removedEdges map[*Edge]bool, removedNodes map[*Node]bool) (Path, error) {
+	distances := map[*Node]float64{src: 0}
+	previous := map[*Node]*EdgeToNode{}
+	visited := map[*Node]bool{}
+	q := &pathQueue{{src, 0, 0}}
+	order := 1
+	for q.Len() > 0 {
+		item := heap.Pop(q).(pathItem)
+		if visited[item.node] {
+			continue
+		}
+		if item.node == dst {
+			p := tracePath(src, dst, previous)
+			p.Cost = item.distance
+			return p, nil
+		}
+		visited[item.node] = true
+		for _, en := range item.node.Edges {
+			if removedEdges[en.Edge] || removedNodes[en.ToNode] || visited[en.ToNode] {
+				continue
+			}
+			c := cost(en.Edge)
+			if c < 0 {
+				return Path{}, fmt.Errorf("PATH ERROR: negative cost %g for edge %q", c, en.Edge.Name)
+			}
+			if math.IsInf(c, 1) {
+				continue
+			}
+			if d, ok := distances[en.ToNode]; !ok || item.distance+c < d {
+				distances[en.ToNode] = item.distance + c
+				previous[en.ToNode] = en
+				heap.Push(q, pathItem{en.ToNode, item.distance + c, order})
+				order++
+			}
+		}
+	}
+	return Path{}, ErrNoPath
+}
+
+func sameEdges(p1, p2 []*Edge) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if p1[i] != p2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPath(paths []Path, p Path) bool {
+	for _, known := range paths {
+		if sameEdges(known.Edges, p.Edges) {
+			return true
+		}
+	}
+	return false
+}
+
+//KShortestPaths returns up to k loopless paths between the nodes, by increasing cost (Yen's algorithm).
+//A nil cost is the InverseWeight, the UnitCost counting the hops. There are no paths for k < 1.
+func (n *Network) KShortestPaths(from, to string, k int, cost EdgeCost) ([]Path, error) {
+	src, dst, err := n.endsOf(from, to)
+	if err != nil || k < 1 {
+		return nil, err
+	}
+	if cost == nil {
+		cost = InverseWeight
+	}
+	first, err := dijkstra(src, dst, cost, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	paths := []Path{first}
+	candidates := []Path{}
+	for len(paths) < k {
+		last := paths[len(paths)-1]
+		for i := 0; i < last.Len(); i++ {
+			// Deviate from the last path at its i-th node, avoiding the edges already taken from the same root
+			spur, rootNodes, rootEdges := last.Nodes[i], last.Nodes[:i+1], last.Edges[:i]
+			removedEdges := map[*Edge]bool{}
+			for _, p := range paths {
+				if p.Len() > i && sameEdges(p.Edges[:i], rootEdges) {
+					removedEdges[p.Edges[i]] = true
+				}
+			}
+			removedNodes := map[*Node]bool{}
+			for _, node := range rootNodes[:i] {
+				removedNodes[node] = true
+			}
+			spurPath, err := dijkstra(spur, dst, cost, removedEdges, removedNodes)
+			if err == ErrNoPath {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			candidate := Path{
+				append(append([]*Node{}, rootNodes[:i]...), spurPath.Nodes...),
+				append(append([]*Edge{}, rootEdges...), spurPath.Edges...),
+				spurPath.Cost,
+			}
+			for _, e := range rootEdges {
+				candidate.Cost += cost(e)
+			}
+			if !containsPath(paths, candidate) && !containsPath(candidates, candidate) {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		// The cheapest candidate, the shortest first among the same costs
+		best := 0
+		for i, c := range candidates {
+			if c.Cost < candidates[best].Cost || c.Cost == candidates[best].Cost && c.Len() < candidates[best].Len() {
+				best = i
+			}
+		}
+		paths = append(paths, candidates[best])
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+	return paths, nil
+}
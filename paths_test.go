@@ -0,0 +1,87 @@
+package go_nets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	fmt.Println("### TESTING the shortest paths")
+	//   a - b - c - d, with a heavy detour a - e - f - g - d and a shortcut b - d
+	network := newTestNetwork("TestPaths", [][3]string{{"a", "b", "ER"}, {"b", "c", "ER"}, {"c", "d", "ER"}, {"b", "d", "ER"},
+		{"a", "e", "ER"}, {"e", "f", "ER"}, {"f", "g", "ER"}, {"g", "d", "ER"}, {"x", "y", "ER"}})
+	for _, name := range []string{"a_e", "e_f", "f_g", "g_d"} {
+		network.Edges[name].Weight = 10
+	}
+	p, err := network.ShortestPath("a", "d")
+	fmt.Println(p)
+	if err != nil || p.Len() != 2 || p.Nodes[1].Name != "b" {
+		t.Errorf("Got %s (%v), expected a -> b -> d", p, err)
+	}
+	// Backwards, through the symmetrical edges
+	if p, err = network.ShortestPath("d", "a"); err != nil || p.String() != "d -[b_d]-> b -[a_b]-> a" {
+		t.Errorf("Got %s (%v), expected d -> b -> a", p, err)
+	}
+	p, err = network.WeightedShortestPath("a", "d", nil)
+	fmt.Println(p, p.Cost)
+	if err != nil || p.Len() != 4 || p.Nodes[1].Name != "e" || math.Abs(p.Cost-0.4) > 1e-9 {
+		t.Errorf("Got %s (%v), expected the heavy detour", p, err)
+	}
+	if _, err = network.ShortestPath("a", "x"); err != ErrNoPath {
+		t.Errorf("Got %v, expected ErrNoPath", err)
+	}
+	if _, err = network.WeightedShortestPath("a", "nobody", nil); err == nil {
+		t.Error("Looking for a path to an unknown node should fail")
+	}
+	// The k shortest paths, by hops
+	paths, err := network.KShortestPaths("a", "d", 5, UnitCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a -[a_b]-> b -[b_d]-> d", "a -[a_b]-> b -[b_c]-> c -[c_d]-> d", "a -[a_e]-> e -[e_f]-> f -[f_g]-> g -[g_d]-> d"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Got %d paths, expected %d", len(paths), len(expected))
+	}
+	for i, p := range paths {
+		fmt.Println(p, p.Cost)
+		if p.String() != expected[i] {
+			t.Errorf("Got %s for the path %d, expected %s", p, i, expected[i])
+		}
+	}
+	if paths, err = network.KShortestPaths("a", "d", 0, UnitCost); err != nil || paths != nil {
+		t.Errorf("Got %v (%v), expected no paths for k = 0", paths, err)
+	}
+	// The edges without weight are impassable
+	network.Edges["a_e"].Weight = 0
+	if p, err = network.WeightedShortestPath("a", "d", nil); err != nil || p.String() != "a -[a_b]-> b -[b_d]-> d" || p.Cost != 2 {
+		t.Errorf("Got %s (%v), expected the path around the edge without weight", p, err)
+	}
+	network.Edges["b_d"].Weight = 0
+	network.Edges["c_d"].Weight = 0
+	if p, err = network.WeightedShortestPath("a", "d", nil); err != ErrNoPath {
+		t.Errorf("Got %s (%v), expected ErrNoPath", p, err)
+	}
+	if paths, err = network.KShortestPaths("a", "d", 5, nil); err != ErrNoPath {
+		t.Errorf("Got %v (%v), expected ErrNoPath", paths, err)
+	}
+	// The filing data on each hop
+	filings := NewNetwork("TestFilingPaths", ioutil.Discard, testFolder)
+	for _, f := range []Filing{
+		newTestFiling(1, []string{"BANK"}, []string{"john.doe"}),
+		newTestFiling(2, []string{"OTHER BANK"}, []string{"john.doe"}),
+	} {
+		filings.AddDispatcher(&f)
+	}
+	p, err = filings.ShortestPath("bank", "other_bank")
+	fmt.Println(p)
+	if err != nil || p.Len() != 2 {
+		t.Fatalf("Got %s (%v), expected bank -> john.doe -> other bank", p, err)
+	}
+	for i, hop := range p.Hops() {
+		if fd, ok := hop.LinkData.(FilingData); !ok || fd.FileNumber != i+1 {
+			t.Errorf("Got the data %v on hop %d, expected the filing %d", hop.LinkData, i, i+1)
+		}
+	}
+}
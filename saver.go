@@ -1,10 +1,14 @@
 package go_nets
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -13,39 +17,114 @@ import (
 // Define a Saver interface and the listening function that goes with it
 //
 
+//A failed batch is rolled back, and its error returned: the saving stops, keeping the batches already saved.
 type Saver interface {
-	SaveBatch([]Saveable) // TODO add error handling
-	InitPersistance(Saveable) chan string
+	SaveBatch([]Saveable) error
+	InitPersistance(Saveable) (chan string, error)
 }
 
 type Saveable interface {
 	GetInitStatements() []string
-	GetSavingStatements() []string
+	GetSavingStatements() []Statement
+}
+
+//Statement is a parameterized query: the Args are bound to its placeholders ("?").
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+//ContextSaver is implemented by the Savers whose batches can be interrupted: a cancelled batch is rolled back.
+type ContextSaver interface {
+	Saver
+	SaveBatchContext(context.Context, []Saveable) error
 }
 
 var BatchSize int = 10000
 
-func ListenAndSave(c chan Saveable, s Saver) {
+//Status sent to the Saver at the end of the saving: everything has been saved,
+//or the saving has been cancelled (the committed batches are kept).
+//The Saver answers with the error of the closing if any, and closes the status channel.
+const (
+	SaveDone      = "done"
+	SaveCancelled = "cancelled"
+)
+
+func ListenAndSave(c chan Saveable, s Saver) error {
+	return ListenAndSaveContext(context.Background(), c, s)
+}
+
+//ListenAndSaveContext saves everything coming from the channel, until it is closed or the context is cancelled.
+//The batch in flight when the context is cancelled is not saved.
+func ListenAndSaveContext(ctx context.Context, c chan Saveable, s Saver) error {
 	// Initialize the saving process
-	first := <-c
-	statusCh := s.InitPersistance(first)
+	var first Saveable
+	select {
+	case saveable, ok := <-c:
+		if !ok { // Nothing to save
+			return nil
+		}
+		first = saveable
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	statusCh, err := s.InitPersistance(first)
+	if err != nil {
+		return err
+	}
 	batchSize := BatchSize
 	// Initialize
 	batch := make([]Saveable, batchSize)
 	batch[0] = first
 	i := 1
-	for saveable := range c {
-		if i == batchSize {
-			s.SaveBatch(batch)
-			i = 0
+	for {
+		select {
+		case saveable, ok := <-c:
+			if !ok {
+				if err := saveBatch(ctx, s, batch[:i]); err != nil {
+					return cancelSaving(statusCh, err)
+				}
+				return closeSaving(statusCh, SaveDone)
+			}
+			if i == batchSize {
+				if err := saveBatch(ctx, s, batch); err != nil {
+					return cancelSaving(statusCh, err)
+				}
+				i = 0
+			}
+			// log.Printf("Filing number %d received, with id %d.", i, saveable.(Filing).FileNumber)
+			batch[i] = saveable
+			i++
+		case <-ctx.Done():
+			return cancelSaving(statusCh, ctx.Err())
 		}
-		// log.Printf("Filing number %d received, with id %d.", i, saveable.(Filing).FileNumber)
-		batch[i] = saveable
-		i++
 	}
-	s.SaveBatch(batch[:i])
-	statusCh <- "done"
-	<-statusCh
+}
+
+func saveBatch(ctx context.Context, s Saver, batch []Saveable) error {
+	if cs, ok := s.(ContextSaver); ok {
+		return cs.SaveBatchContext(ctx, batch)
+	}
+	if err := s.SaveBatch(batch); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func cancelSaving(statusCh chan string, err error) error {
+	log.Println("SAVE_INTERRUPTED:", err)
+	if errClose := closeSaving(statusCh, SaveCancelled); errClose != nil {
+		log.Println("SAVE_ERROR:", errClose)
+	}
+	return err
+}
+
+func closeSaving(statusCh chan string, status string) error {
+	statusCh <- status
+	if msg, ok := <-statusCh; ok {
+		return errors.New(msg)
+	}
+	return nil
 }
 
 //////////
@@ -53,37 +132,114 @@ func ListenAndSave(c chan Saveable, s Saver) {
 //
 type SqlSaver struct {
 	DbPath, DbName string
-	DBDriver       string
+	DBDriver       string // Selects the Dialect, see RegisterDialect
+	Mode           SaveMode
+	DeadLetters    DeadLetterSink // Receives the rejected records (can be nil)
 	currentDB      *sql.DB
+	dialect        Dialect
+	summary        SaveSummary
+}
+
+//SaveMode tells what the SqlSaver does with the database of a previous run.
+type SaveMode int
+
+const (
+	Rebuild SaveMode = iota // Start from scratch
+	Resume                  // Append to the database, the parsers skipping the records already saved (see Checkpoints)
+	Append                  // Upsert into a copy of the existing database, which is replaced once the saving is done
+)
+
+func (ss *SqlSaver) tempFilePath() string {
+	return ss.DbPath + ss.DbName + TempSuffix
+}
+
+func (ss *SqlSaver) finalFilePath() string {
+	return ss.DbPath + ss.DbName + ".sqlite"
+}
+
+//Checkpoints returns, for each source of Checkpointed records, the number of records already saved.
+//It is empty unless the saver resumes the saving into an existing database.
+func (ss *SqlSaver) Checkpoints() (map[string]int, error) {
+	checkpoints := map[string]int{}
+	if ss.Mode != Resume {
+		return checkpoints, nil
+	}
+	d, err := dialectOf(ss.DBDriver)
+	if err != nil {
+		return nil, err
+	}
+	dbPath := ss.tempFilePath()
+	if !d.Exists(dbPath) {
+		if dbPath = ss.finalFilePath(); !d.Exists(dbPath) {
+			return checkpoints, nil // Nothing saved yet
+		}
+	}
+	db, err := d.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if _, err = db.Exec(checkpointsTable); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT source, records FROM checkpoints")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			source  string
+			records int
+		)
+		if err = rows.Scan(&source, &records); err != nil {
+			return nil, err
+		}
+		checkpoints[source] = records
+	}
+	return checkpoints, rows.Err()
 }
 
-func (ss *SqlSaver) InitPersistance(so Saveable) chan string {
+func (ss *SqlSaver) InitPersistance(so Saveable) (chan string, error) {
 
 	// Prepare
 	log.Println("Initializing sqlite db...")
 	t0 := time.Now()
-	tempFilePath := ss.DbPath + ss.DbName + TempSuffix
-	os.Remove(tempFilePath)
+	ss.summary = SaveSummary{Reasons: map[string]int{}}
+	d, err := dialectOf(ss.DBDriver)
+	if err != nil {
+		return nil, err
+	}
+	ss.dialect = d
+	tempFilePath := ss.tempFilePath()
+	if ss.Mode == Resume {
+		// Carry on with the temporary file of an interrupted saving, or with the final file of a completed one
+		if !d.Exists(tempFilePath) && d.Exists(ss.finalFilePath()) {
+			if err := d.Rename(ss.finalFilePath(), tempFilePath); err != nil {
+				return nil, err
+			}
+		}
+		log.Println("Resuming the saving into", tempFilePath)
+	} else if err := d.Remove(tempFilePath); err != nil {
+		return nil, err
+	}
+	if ss.Mode == Append && d.Exists(ss.finalFilePath()) {
+		if err := d.Copy(ss.finalFilePath(), tempFilePath); err != nil {
+			return nil, err
+		}
+		log.Println("Appending to", ss.finalFilePath())
+	}
 
 	// Open/Create the database
-	db, err := sql.Open(ss.DBDriver, tempFilePath)
+	db, err := d.Open(tempFilePath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
-	if err != nil {
-		log.Fatal(err)
+	if err = ss.initDB(db, so); err != nil {
+		db.Close()
+		return nil, err
 	}
 	ss.currentDB = db
-
-	// Initialize the db (Create the tables...)
-	for _, sqlStmt := range so.GetInitStatements() {
-		_, err = db.Exec(sqlStmt)
-		if err != nil {
-			// log.Printf("%#v", err) //AL DEBUG
-			log.Printf("%q: %s\n", err, sqlStmt)
-		}
-	}
 	log.Println("DB Initialized.")
 
 	// Close the db
@@ -91,62 +247,242 @@ func (ss *SqlSaver) InitPersistance(so Saveable) chan string {
 	go func() {
 		status := <-statusCh
 		// log.Println("receiving %s ...", status)
-		if status == "done" { // TODO: Rearrange closing db
-			err := db.Close()
-			if err != nil {
-				log.Fatal(err)
+		err := db.Close()
+		switch {
+		case err != nil:
+		case status == SaveDone:
+			if err = d.Rename(tempFilePath, ss.finalFilePath()); err == nil {
+				log.Printf("\n Successfully saved the filings in %v \n", time.Now().Sub(t0))
 			}
-			os.Rename(tempFilePath, ss.DbPath+ss.DbName+".sqlite")
-			log.Printf("\n Successfully saved the filings in %v \n", time.Now().Sub(t0))
-			log.Println("### ---------------")
-			close(statusCh)
+		case status == SaveCancelled: // Only the committed batches are in the temporary file
+			log.Printf("\n Saving cancelled after %v, the committed batches are kept in %s \n", time.Now().Sub(t0), tempFilePath)
 		}
+		log.Println(ss.summary)
+		log.Println("### ---------------")
+		if err != nil {
+			statusCh <- err.Error()
+		}
+		close(statusCh)
 	}()
 
-	return (statusCh)
+	return statusCh, nil
+}
+
+//Migrator is implemented by the Saveables that update the databases saved by their previous versions.
+//The migrations are executed after the init statements.
+type Migrator interface {
+	GetMigrations(Dialect) []string
+}
+
+//initDB creates the tables.
+func (ss *SqlSaver) initDB(db *sql.DB, so Saveable) error {
+	sqlStmts := append([]string{checkpointsTable}, so.GetInitStatements()...)
+	if m, ok := so.(Migrator); ok {
+		sqlStmts = append(sqlStmts, m.GetMigrations(ss.dialect)...)
+	}
+	for _, sqlStmt := range sqlStmts {
+		if _, err := db.Exec(sqlStmt); err != nil {
+			return fmt.Errorf("%v: %s", err, sqlStmt)
+		}
+	}
+	if ss.Mode == Append { // The checkpoints are the ones of the current saving
+		if _, err := db.Exec("DELETE FROM checkpoints"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *SqlSaver) SaveBatch(ss []Saveable) {
+//Summary sums up the current (or last) saving.
+func (ss *SqlSaver) Summary() SaveSummary {
+	return ss.summary
+}
+
+func (s *SqlSaver) SaveBatch(ss []Saveable) error {
+	return s.SaveBatchContext(context.Background(), ss)
+}
+
+//SaveBatchContext saves the batch in one transaction, rolled back if the context is cancelled or if
+//the transaction fails. A saveable whose statements fail is rejected alone.
+func (s *SqlSaver) SaveBatchContext(ctx context.Context, ss []Saveable) error {
 	// Begin transaction
 	log.Println("Beginning Transaction...")
-	tx, err := s.currentDB.Begin()
+	tx, err := s.currentDB.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	// Load the statements
+	// Load the statements, each query being prepared once per batch
+	preparedStmts := make(map[string]*sql.Stmt)
+	defer func() {
+		for _, stmt := range preparedStmts {
+			stmt.Close()
+		}
+	}()
+	saved := 0
 	for _, saveable := range ss {
-		for _, sqlStmt := range saveable.GetSavingStatements() {
-			_, err = tx.Exec(sqlStmt)
-			if err != nil {
-				log.Printf("%q: %s\n", err, sqlStmt)
+		ok, err := s.saveOne(ctx, tx, saveable, func() error {
+			for _, sqlStmt := range saveable.GetSavingStatements() {
+				stmt, ok := preparedStmts[sqlStmt.Query]
+				if !ok {
+					var err error
+					if stmt, err = tx.PrepareContext(ctx, s.dialect.Rebind(sqlStmt.Query)); err != nil {
+						log.Printf("%q: %s\n", err, sqlStmt.Query)
+						return err
+					}
+					preparedStmts[sqlStmt.Query] = stmt
+				}
+				if _, err := stmt.ExecContext(ctx, sqlStmt.Args...); err != nil {
+					log.Printf("%q: %s %v\n", err, sqlStmt.Query, sqlStmt.Args)
+					return err
+				}
 			}
+			return nil
+		})
+		if err != nil {
+			return rollback(tx, err)
+		}
+		if ok {
+			saved++
+		}
+	}
+	if err = saveCheckpoints(ctx, tx, s.dialect, ss); err != nil {
+		return rollback(tx, err)
+	}
+	return s.commit(tx, saved)
+}
+
+//saveOne saves one saveable in its own savepoint: when one of its statements fails, only this saveable
+//is rolled back, and sent to the dead letters. The error is returned when the whole batch has to be rolled back.
+func (s *SqlSaver) saveOne(ctx context.Context, tx *sql.Tx, saveable Saveable, exec func() error) (bool, error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT saveable"); err != nil {
+		return false, err
+	}
+	errExec := exec()
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if errExec != nil {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO saveable"); err != nil {
+			return false, err
 		}
+		s.reject(saveable, errExec)
 	}
-	// Commit
+	_, err := tx.ExecContext(ctx, "RELEASE saveable")
+	return errExec == nil && err == nil, err
+}
+
+func (s *SqlSaver) reject(saveable Saveable, err error) {
+	s.summary.Rejected++
+	s.summary.Reasons[err.Error()]++
+	if s.DeadLetters != nil {
+		s.DeadLetters.Reject(saveable, err)
+	}
+}
+
+func (s *SqlSaver) commit(tx *sql.Tx, saved int) error {
 	log.Println("Commiting Transaction...")
-	tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.summary.Saved += saved
 	log.Println("Transaction committed.")
+	return nil
+}
+
+func rollback(tx *sql.Tx, err error) error {
+	log.Println("Rolling back Transaction...")
+	if errRb := tx.Rollback(); errRb != nil && errRb != sql.ErrTxDone {
+		log.Println("SAVE_ERROR:", errRb)
+	}
+	return err
 }
 
 //////////
-// Implement the Filing as a saveable object. Rely on the primary keys mechanics and other sql constraints for uniqueness.
+// Report of the saving: the rejected records go to the dead letters, and the summary counts them by reason.
 //
 
+//DeadLetterSink receives the records rejected by the database, with the reason.
+type DeadLetterSink interface {
+	Reject(Saveable, error)
+}
+
+//DeadLetterWriter writes the rejected records as JSON Lines: {"Error": ..., "Record": ...}.
+type DeadLetterWriter struct {
+	Writer io.Writer
+}
+
+func (w DeadLetterWriter) Reject(saveable Saveable, err error) {
+	b, errJSON := json.Marshal(struct {
+		Error  string
+		Record Saveable
+	}{err.Error(), saveable})
+	if errJSON == nil {
+		_, errJSON = w.Writer.Write(append(b, '\n'))
+	}
+	if errJSON != nil {
+		log.Println("DEAD_LETTER_ERROR:", errJSON)
+	}
+}
+
+//SaveSummary sums up a saving.
+type SaveSummary struct {
+	Saved    int
+	Rejected int
+	Reasons  map[string]int // Number of rejected records per error
+}
+
+func (s SaveSummary) String() string {
+	summary := fmt.Sprintf("%d records saved, %d rejected", s.Saved, s.Rejected)
+	reasons := make([]string, 0, len(s.Reasons))
+	for reason, n := range s.Reasons {
+		reasons = append(reasons, fmt.Sprintf("\n  %d: %s", n, reason))
+	}
+	sort.Strings(reasons)
+	return summary + strings.Join(reasons, "")
+}
+
+//////////
+// Implement the Filing as a saveable object. Rely on the primary keys mechanics and other sql constraints for uniqueness:
+// the filings and agents are upserted, and the lookups saved only once, so that re-delivered filings can be saved again.
+//
+
+var (
+	filingColumns = []string{"filingid", "original_file_number", "file_number", "original_date", "date", "xmlname", "method", "amendment", "type", "alt_type"}
+	agentColumns  = []string{"agentid", "organisation_name", "first_name", "middle_name", "last_name", "mail_address", "city", "state", "postal_code", "country"}
+)
+
+//upsertStatement inserts the values in the table, or updates the row with the same key (the first column).
+func upsertStatement(table string, columns []string, values string) string {
+	updates := make([]string, len(columns)-1)
+	for i, column := range columns[1:] {
+		updates[i] = column + " = excluded." + column
+	}
+	return "INSERT INTO " + table + " VALUES (" + values + ") ON CONFLICT(" + columns[0] + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+//uniqueLookup removes the duplicates saved before the lookup table had its unique index, and creates it.
+func uniqueLookup(d Dialect, table string) []string {
+	return []string{
+		d.Dedupe(table, "filingid", "agentid"),
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + table + "_filing_agent ON " + table + " (filingid, agentid)",
+	}
+}
+
 func (f Filing) GetInitStatements() []string {
 	return []string{
-		`CREATE TABLE filings (
-				filingid INT PRIMARY KEY NOT NULL,
-				original_file_number INT,
-				file_number INT NOT NULL,
+		`CREATE TABLE IF NOT EXISTS filings (
+				filingid BIGINT PRIMARY KEY NOT NULL,
+				original_file_number BIGINT,
+				file_number BIGINT NOT NULL,
 				original_date TEXT,
-				date TEST,
+				date TEXT,
 				xmlname VARCHAR(50),
 				method VARCHAR(50),
 				amendment VARCHAR(50),
 				type VARCHAR(50),
 				alt_type VARCHAR(50)
 			)`, // BTW, string length are not inforced by sqlite. Also, NOT NULL is necessary for primary keys
-		`CREATE TABLE agents (
+		`CREATE TABLE IF NOT EXISTS agents (
 		agentid TEXT PRIMARY KEY NOT NULL,
 		organisation_name VARCHAR(250),
 		first_name VARCHAR(250),
@@ -158,14 +494,14 @@ func (f Filing) GetInitStatements() []string {
 		postal_code VARCHAR(250),
 		country VARCHAR(250)
 		)`,
-		`CREATE TABLE debtors (
-		filingid INT,
+		`CREATE TABLE IF NOT EXISTS debtors (
+		filingid BIGINT,
 		agentid TEXT,
 		FOREIGN KEY(filingid) REFERENCES filings(filingid),
 		FOREIGN KEY(agentid) REFERENCES agents(agentid)
 		)`,
-		`CREATE TABLE securers (
-		filingid INT,
+		`CREATE TABLE IF NOT EXISTS securers (
+		filingid BIGINT,
 		agentid TEXT,
 		FOREIGN KEY(filingid) REFERENCES filings(filingid),
 		FOREIGN KEY(agentid) REFERENCES agents(agentid)
@@ -173,11 +509,16 @@ func (f Filing) GetInitStatements() []string {
 	}
 }
 
-func (f Filing) GetSavingStatements() []string {
-	sqlStmts := []string{}
+func (f Filing) GetMigrations(d Dialect) []string {
+	return append(uniqueLookup(d, "debtors"), uniqueLookup(d, "securers")...)
+}
+
+func (f Filing) GetSavingStatements() []Statement {
+	sqlStmts := []Statement{}
 	// Add the filing itself
-	sqlStmts = append(sqlStmts,
-		fmt.Sprintf("INSERT INTO filings VALUES ("+strings.Repeat("\"%v\", ", 9)+"\"%v\""+")",
+	sqlStmts = append(sqlStmts, Statement{
+		upsertStatement("filings", filingColumns, strings.Repeat("?, ", 9)+"?"),
+		[]interface{}{
 			f.FileNumber,
 			f.OriginalFileNumber,
 			f.FileNumber,
@@ -187,49 +528,35 @@ func (f Filing) GetSavingStatements() []string {
 			f.Method.Attr,
 			f.Amendment.Attr,
 			f.FilingType.Attr,
-			f.AltFilingType.Attr),
-	)
-	// Add the debtors and their lookups
+			f.AltFilingType.Attr},
+	})
+	// Add the debtors and the securers, and their lookups
 	for _, d := range f.Debtors {
-		sqlStmts = append(sqlStmts,
-			fmt.Sprintf("INSERT OR IGNORE INTO agents VALUES ("+strings.Repeat("\"%v\", ", 9)+"\"%v\""+")",
-				d.GetIdentifier(),
-				d.OrganizationName,
-				d.IndividualName.FirstName,
-				d.IndividualName.MiddleName,
-				d.IndividualName.LastName,
-				d.MailAddress,
-				d.City,
-				d.State,
-				d.PostalCode,
-				d.Country),
-			fmt.Sprintf("INSERT INTO debtors VALUES (\"%v\", \"%v\")",
-				f.FileNumber,
-				d.GetIdentifier()),
-		)
+		sqlStmts = append(sqlStmts, d.agentStatement(), Statement{"INSERT INTO debtors VALUES (?, ?) ON CONFLICT DO NOTHING", []interface{}{f.FileNumber, d.GetIdentifier()}})
 	}
-	// Add the securers and their lookups
 	for _, sec := range f.Securers {
-		sqlStmts = append(sqlStmts,
-			fmt.Sprintf("INSERT OR IGNORE INTO agents VALUES ("+strings.Repeat("\"%v\", ", 9)+"\"%v\""+")",
-				sec.GetIdentifier(),
-				sec.OrganizationName,
-				sec.IndividualName.FirstName,
-				sec.IndividualName.MiddleName,
-				sec.IndividualName.LastName,
-				sec.MailAddress,
-				sec.City,
-				sec.State,
-				sec.PostalCode,
-				sec.Country),
-			fmt.Sprintf("INSERT INTO securers VALUES (\"%v\", \"%v\")",
-				f.FileNumber,
-				sec.GetIdentifier()),
-		)
+		sqlStmts = append(sqlStmts, sec.agentStatement(), Statement{"INSERT INTO securers VALUES (?, ?) ON CONFLICT DO NOTHING", []interface{}{f.FileNumber, sec.GetIdentifier()}})
 	}
 	return sqlStmts
 }
 
+func (a Agent) agentStatement() Statement {
+	return Statement{
+		upsertStatement("agents", agentColumns, strings.Repeat("?, ", 9)+"?"),
+		[]interface{}{
+			a.GetIdentifier(),
+			a.OrganizationName,
+			a.IndividualName.FirstName,
+			a.IndividualName.MiddleName,
+			a.IndividualName.LastName,
+			a.MailAddress,
+			a.City,
+			a.State,
+			a.PostalCode,
+			a.Country},
+	}
+}
+
 func FilingToSaveable(from <-chan Filing) chan Saveable {
 	to := make(chan Saveable)
 	go func() {
@@ -242,127 +569,83 @@ func FilingToSaveable(from <-chan Filing) chan Saveable {
 	return to
 }
 
-///////////
-// Implement a filing specific version of the SaveBatch in order to speed up greatly the execution thanks to prepared state;ents
-// Ends up being the same speed, but more reliable bc no need for value-quoting in the SQL statement.
+func RecordToSaveable(from <-chan Record) chan Saveable {
+	to := make(chan Saveable)
+	go func() {
+		for r := range from {
+			to <- r
+		}
+		close(to)
+	}()
+	return to
+}
+
+//////////
+// Checkpoints of the saving: how many records of each source are saved, updated in the transaction of each batch.
+// The records of a source are parsed and saved in order, so that a resumed parser just skips this many records.
 //
 
-func ListenAndSaveFilings(c chan Filing, s *SqlSaver) {
-	// Initialize the saving process
-	first := <-c
-	statusCh := s.InitPersistance(first)
-	batchSize := BatchSize
-	// Initialize
-	batch := make([]Filing, batchSize)
-	batch[0] = first
-	i := 1
-	for filing := range c {
-		if i == batchSize {
-			s.SaveFilingBatch(batch)
-			i = 0
+//Checkpointed is implemented by the Saveables that know their source, and their index in it (see Sourced).
+type Checkpointed interface {
+	Checkpoint() (source string, record int)
+}
+
+var checkpointColumns = []string{"source", "records", "updated"}
+
+const checkpointsTable = `CREATE TABLE IF NOT EXISTS checkpoints (
+		source TEXT PRIMARY KEY NOT NULL,
+		records INT NOT NULL,
+		updated TEXT
+		)`
+
+func saveCheckpoints(ctx context.Context, tx *sql.Tx, d Dialect, ss []Saveable) error {
+	checkpoints := map[string]int{}
+	for _, saveable := range ss {
+		if c, ok := saveable.(Checkpointed); ok {
+			addCheckpoint(checkpoints, c)
 		}
-		// log.Printf("Filing number %d received, with id %d.", i, saveable.(Filing).FileNumber)
-		batch[i] = filing
-		i++
 	}
-	s.SaveFilingBatch(batch[:i])
-	statusCh <- "done"
-	<-statusCh
+	return updateCheckpoints(ctx, tx, d, checkpoints)
 }
 
-//
-func (ss *SqlSaver) SaveFilingBatch(batch []Filing) {
-	// Begin Transaction
-	log.Println("Beginning Transaction...")
-	tx, err := ss.currentDB.Begin()
-	if err != nil {
-		log.Fatal(err)
+func addCheckpoint(checkpoints map[string]int, c Checkpointed) {
+	if source, record := c.Checkpoint(); source != "" && record > checkpoints[source] {
+		checkpoints[source] = record
 	}
+}
 
-	// Prepare Statements
-	preparationStmts := map[string]string{
-		"filings":  "INSERT INTO filings VALUES (" + strings.Repeat("?, ", 9) + "?" + ")",
-		"agents":   "INSERT OR IGNORE INTO agents VALUES (" + strings.Repeat("?, ", 9) + "?" + ")",
-		"debtors":  "INSERT INTO debtors VALUES (?, ?)",
-		"securers": "INSERT INTO securers VALUES (?, ?)",
-	}
-	preparedStmts := make(map[string]*sql.Stmt)
-	for key, stmt := range preparationStmts {
-		preparedStmts[key], err = tx.Prepare(stmt)
-		if err != nil {
-			log.Fatal(err)
+func updateCheckpoints(ctx context.Context, tx *sql.Tx, d Dialect, checkpoints map[string]int) error {
+	updated := formatTime(time.Now())
+	for source, records := range checkpoints {
+		if _, err := tx.ExecContext(ctx, d.Rebind(upsertStatement("checkpoints", checkpointColumns, "?, ?, ?")), source, records, updated); err != nil {
+			return err
 		}
-		defer preparedStmts[key].Close()
 	}
+	return nil
+}
 
-	// Add Statements
-	for _, f := range batch {
-		// Add the filing itself
-		_, err = preparedStmts["filings"].Exec(
-			f.FileNumber,
-			f.OriginalFileNumber,
-			f.FileNumber,
-			f.OriginalFileDate,
-			f.FileDate,
-			f.XMLName.Local,
-			f.Method.Attr,
-			f.Amendment.Attr,
-			f.FilingType.Attr,
-			f.AltFilingType.Attr)
-		if err != nil {
-			log.Printf("%q: %v\n", err, f.FileNumber)
-		}
-		// Add the debtors and their lookups
-		for _, d := range f.Debtors {
-			_, err = preparedStmts["agents"].Exec(
-				d.GetIdentifier(),
-				d.OrganizationName,
-				d.IndividualName.FirstName,
-				d.IndividualName.MiddleName,
-				d.IndividualName.LastName,
-				d.MailAddress,
-				d.City,
-				d.State,
-				d.PostalCode,
-				d.Country)
-			if err != nil {
-				log.Printf("%q. As Debtor: %s\n", err, d.GetIdentifier())
-			}
-			_, err = preparedStmts["debtors"].Exec(
-				f.FileNumber,
-				d.GetIdentifier())
-			if err != nil {
-				log.Printf("%q\n", err)
-			}
-		}
-		// Add the securers and their lookups
-		for _, sec := range f.Securers {
-			_, err = preparedStmts["agents"].Exec(
-				sec.GetIdentifier(),
-				sec.OrganizationName,
-				sec.IndividualName.FirstName,
-				sec.IndividualName.MiddleName,
-				sec.IndividualName.LastName,
-				sec.MailAddress,
-				sec.City,
-				sec.State,
-				sec.PostalCode,
-				sec.Country)
-			if err != nil {
-				log.Printf("%q. As Securer: %s \n", err, sec.GetIdentifier())
-			}
-			_, err = preparedStmts["securers"].Exec(
-				f.FileNumber,
-				sec.GetIdentifier())
-			if err != nil {
-				log.Printf("%q\n", err)
-			}
-		}
-	}
+//////////
+// Save the filings straight from the parsers
+//
 
-	// Commit
-	log.Println("Commiting Transaction...")
-	tx.Commit()
-	log.Println("Transaction committed.")
+func ListenAndSaveFilings(c chan Filing, s Saver) error {
+	return ListenAndSaveFilingsContext(context.Background(), c, s)
+}
 
+//ListenAndSaveFilingsContext is ListenAndSaveContext for a channel of Filings.
+//The filings received once the saving has stopped, cancelled or failed, are dropped so that their producer can finish.
+func ListenAndSaveFilingsContext(ctx context.Context, c chan Filing, s Saver) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	to := make(chan Saveable)
+	go func() {
+		defer close(to)
+		for f := range c {
+			select {
+			case to <- f:
+			case <-ctx.Done(): // Drop it, the saving has stopped
+			}
+		}
+	}()
+	return ListenAndSaveContext(ctx, to, s)
 }
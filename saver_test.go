@@ -1,9 +1,16 @@
 package go_nets
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"code.google.com/p/go.text/encoding/charmap"
 )
@@ -34,7 +41,7 @@ func TestSaver(t *testing.T) {
 	ListenAndSave(FilingToSaveable(cs), TestSaver)
 }
 
-func TestDirectSaver(t *testing.T) { // Gets more than above because of the non-discarded filings with " inside the fields.
+func TestDirectSaver(t *testing.T) { // Same as above, the filings are saved with the same prepared statements
 	filename := "UMtest2.xml"
 	fmt.Println("### TESTING the saver (big file)")
 	Parser := XmlParser{
@@ -55,3 +62,287 @@ func TestDirectSaver(t *testing.T) { // Gets more than above because of the non-
 	go Parser.Parse(cs, fi)
 	ListenAndSaveFilings(cs, TestSaver)
 }
+
+func TestSaverCancel(t *testing.T) {
+	fmt.Println("### TESTING the cancellation of the saver")
+	os.Mkdir(testFolder, os.FileMode(0777))
+	os.Remove(testFolder + "TestSaverCancel.sqlite")
+	defer func(batchSize int) { BatchSize = batchSize }(BatchSize)
+	BatchSize = 5
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan Saveable)
+	errCh := make(chan error)
+	go func() {
+		errCh <- ListenAndSaveContext(ctx, c, &SqlSaver{DbPath: testFolder, DbName: "TestSaverCancel", DBDriver: "sqlite3"})
+	}()
+	for i := 0; i < 12; i++ {
+		c <- &testOfficer{"ACME", fmt.Sprint("Officer ", i), "CEO"}
+	}
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Errorf("Got error %v, expected %v", err, context.Canceled)
+	}
+	// Only the committed batches are in the temporary file
+	if _, err := os.Stat(testFolder + "TestSaverCancel.sqlite"); err == nil {
+		t.Error("A cancelled saving should not produce the final database")
+	}
+	db, err := sql.Open("sqlite3", testFolder+"TestSaverCancel"+TempSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow("SELECT COUNT(*) FROM officers").Scan(&n); err != nil || n != 10 {
+		t.Errorf("Got %d officers saved (%v), expected the 2 committed batches of 5", n, err)
+	}
+}
+
+//The failingSaver fails to save any batch.
+type failingSaver struct{}
+
+func (failingSaver) InitPersistance(Saveable) (chan string, error) {
+	statusCh := make(chan string)
+	go func() {
+		<-statusCh
+		close(statusCh)
+	}()
+	return statusCh, nil
+}
+
+func (failingSaver) SaveBatch([]Saveable) error {
+	return errors.New("no space left on device")
+}
+
+func TestSaverFailure(t *testing.T) {
+	fmt.Println("### TESTING the failure of the saver")
+	const name = "TestSaverFailure"
+	os.Mkdir(testFolder, os.FileMode(0777))
+	defer func(batchSize int) { BatchSize = batchSize }(BatchSize)
+	BatchSize = 2
+	lines := ""
+	for i := 1; i <= 10; i++ {
+		lines += fmt.Sprintf(`{"FileNumber": %d, "Debtors": [{"OrganizationName": "DEBTOR %d"}], "Secured": [{"OrganizationName": "BANK"}]}`+"\n", i, i)
+	}
+	if err := ioutil.WriteFile(testFolder+name+".jsonl", []byte(lines), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cs := make(chan Filing)
+	reportCh := make(chan ParseReport, 1)
+	go func() {
+		report, _ := NewFilingParser(testFolder, name+".jsonl", nil).Parse(cs, ioutil.Discard)
+		reportCh <- report
+	}()
+	errCh := make(chan error, 1)
+	go func() { errCh <- ListenAndSaveFilings(cs, failingSaver{}) }()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("Got no error, expected the failure of the saver")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The saving hangs after the failure of the saver")
+	}
+	// The parser is not left blocked on the channel
+	select {
+	case report := <-reportCh:
+		if report.Parsed != 10 {
+			t.Errorf("Got %v, expected the 10 filings parsed and dropped", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("The parser is blocked after the failure of the saver")
+	}
+}
+
+func TestSaverResume(t *testing.T) {
+	fmt.Println("### TESTING the resuming of the saver")
+	const name = "TestSaverResume"
+	os.Mkdir(testFolder, os.FileMode(0777))
+	os.Remove(testFolder + name + ".sqlite")
+	os.Remove(testFolder + name + TempSuffix)
+	defer func(batchSize int) { BatchSize = batchSize }(BatchSize)
+	BatchSize = 2
+	lines := ""
+	addFilings := func(from, to int) {
+		for i := from; i <= to; i++ {
+			lines += fmt.Sprintf(`{"FileNumber": %d, "Debtors": [{"OrganizationName": "DEBTOR %d"}], "Secured": [{"OrganizationName": "BANK"}]}`+"\n", i, i)
+		}
+		if err := ioutil.WriteFile(testFolder+name+".jsonl", []byte(lines), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save := func(mode SaveMode) ParseReport {
+		saver := &SqlSaver{DbPath: testFolder, DbName: name, DBDriver: "sqlite3", Mode: mode}
+		checkpoints, err := saver.Checkpoints()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs := make(chan Filing)
+		reportCh := make(chan ParseReport, 1)
+		go func() {
+			report, _ := ResumeFilingParser(testFolder, name+".jsonl", nil, checkpoints[name+".jsonl"]).Parse(cs, ioutil.Discard)
+			reportCh <- report
+		}()
+		ListenAndSaveFilings(cs, saver)
+		return <-reportCh
+	}
+	// A first saving, then new filings appended to the file
+	addFilings(1, 3)
+	if report := save(Rebuild); report.Parsed != 3 {
+		t.Errorf("Got %v, expected the 3 filings", report)
+	}
+	addFilings(4, 5)
+	if report := save(Resume); report.Skipped != 3 || report.Parsed != 2 {
+		t.Errorf("Got %v, expected the 3 saved filings skipped", report)
+	}
+	db, err := sql.Open("sqlite3", testFolder+name+".sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n, records int
+	if err = db.QueryRow("SELECT COUNT(*) FROM filings").Scan(&n); err != nil || n != 5 {
+		t.Errorf("Got %d filings saved (%v), expected 5", n, err)
+	}
+	if err = db.QueryRow("SELECT records FROM checkpoints WHERE source = ?", name+".jsonl").Scan(&records); err != nil || records != 5 {
+		t.Errorf("Got a checkpoint at %d (%v), expected 5", records, err)
+	}
+}
+
+func TestSaverAppend(t *testing.T) {
+	fmt.Println("### TESTING the appending to an existing database")
+	const name = "TestSaverAppend"
+	os.Mkdir(testFolder, os.FileMode(0777))
+	os.Remove(testFolder + name + ".sqlite")
+	newFiling := func(fileNumber int, fileDate string) Filing {
+		return Filing{FileNumber: fileNumber, FileDate: fileDate,
+			Debtors: []Agent{Agent{OrganizationName: fmt.Sprint("DEBTOR ", fileNumber)}}, Securers: []Agent{Agent{OrganizationName: "BANK"}}}
+	}
+	send := func(filings ...Filing) chan Filing {
+		c := make(chan Filing)
+		go func() {
+			for _, f := range filings {
+				c <- f
+			}
+			close(c)
+		}()
+		return c
+	}
+	ListenAndSaveFilings(send(newFiling(1, "20130101 0900"), newFiling(2, "20130101 0900")), &SqlSaver{DbPath: testFolder, DbName: name, DBDriver: "sqlite3"})
+	// Duplicates saved by a previous version, without the unique index
+	db, err := sql.Open("sqlite3", testFolder+name+".sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{"DROP INDEX debtors_filing_agent", "INSERT INTO debtors SELECT * FROM debtors"} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	// The second filing is re-delivered, with a correction
+	appending := &SqlSaver{DbPath: testFolder, DbName: name, DBDriver: "sqlite3", Mode: Append}
+	ListenAndSave(FilingToSaveable(send(newFiling(2, "20130102 0900"), newFiling(3, "20130102 0900"))), appending)
+	if db, err = sql.Open("sqlite3", testFolder+name+".sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var (
+		date             string
+		n, nd, nDistinct int
+	)
+	if err = db.QueryRow("SELECT COUNT(*) FROM filings").Scan(&n); err != nil || n != 3 {
+		t.Errorf("Got %d filings (%v), expected 3", n, err)
+	}
+	if err = db.QueryRow("SELECT date FROM filings WHERE filingid = 2").Scan(&date); err != nil || date != "20130102 0900" {
+		t.Errorf("Got %q (%v), expected the re-delivered filing to be updated", date, err)
+	}
+	if err = db.QueryRow("SELECT COUNT(*), COUNT(DISTINCT filingid || agentid) FROM debtors").Scan(&nd, &nDistinct); err != nil || nd != 3 || nDistinct != 3 {
+		t.Errorf("Got %d debtors for %d distinct ones (%v), expected 3 without duplicates", nd, nDistinct, err)
+	}
+}
+
+//The odd lines are rejected by the database, after their first statement.
+type testLine struct {
+	Number int
+}
+
+func (l testLine) GetInitStatements() []string {
+	return []string{"CREATE TABLE IF NOT EXISTS lines (number INT)"}
+}
+
+func (l testLine) GetSavingStatements() []Statement {
+	stmts := []Statement{{"INSERT INTO lines VALUES (?)", []interface{}{l.Number}}}
+	if l.Number%2 == 1 {
+		stmts = append(stmts, Statement{"INSERT INTO missing VALUES (?)", []interface{}{l.Number}})
+	}
+	return stmts
+}
+
+func TestSaverDeadLetters(t *testing.T) {
+	fmt.Println("### TESTING the rejection of the records")
+	const name = "TestSaverDeadLetters"
+	os.Mkdir(testFolder, os.FileMode(0777))
+	defer func(batchSize int) { BatchSize = batchSize }(BatchSize)
+	BatchSize = 3
+	deadLetters := &bytes.Buffer{}
+	saver := &SqlSaver{DbPath: testFolder, DbName: name, DBDriver: "sqlite3", DeadLetters: DeadLetterWriter{deadLetters}}
+	c := make(chan Saveable)
+	go func() {
+		for i := 0; i < 10; i++ {
+			c <- testLine{i}
+		}
+		close(c)
+	}()
+	if err := ListenAndSave(c, saver); err != nil {
+		t.Fatal(err)
+	}
+	summary := saver.Summary()
+	fmt.Println(summary)
+	if summary.Saved != 5 || summary.Rejected != 5 || len(summary.Reasons) != 1 {
+		t.Errorf("Got %v, expected the 5 odd lines rejected for the same reason", summary)
+	}
+	if rejected := strings.Split(strings.TrimSpace(deadLetters.String()), "\n"); len(rejected) != 5 ||
+		!strings.Contains(rejected[0], `"Record":{"Number":1}`) {
+		t.Errorf("Got the dead letters:\n%s", deadLetters)
+	}
+	// The rejected lines are entirely rolled back
+	db, err := sql.Open("sqlite3", testFolder+name+".sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow("SELECT COUNT(*) FROM lines WHERE number % 2 = 0").Scan(&n); err != nil || n != 5 {
+		t.Errorf("Got %d even lines (%v), expected 5", n, err)
+	}
+	if err = db.QueryRow("SELECT COUNT(*) FROM lines").Scan(&n); err != nil || n != 5 {
+		t.Errorf("Got %d lines (%v), expected only the 5 even ones", n, err)
+	}
+}
+
+func TestSaverQuotes(t *testing.T) {
+	fmt.Println("### TESTING the saving of values with quotes")
+	const name = "TestSaverQuotes"
+	os.Mkdir(testFolder, os.FileMode(0777))
+	names := []string{`O"BRIEN & SONS`, `D'ANGELO`, `"); DROP TABLE agents; --`}
+	c := make(chan Filing)
+	go func() {
+		c <- Filing{FileNumber: 1, Debtors: []Agent{{OrganizationName: names[0]}, {OrganizationName: names[1]}}, Securers: []Agent{{OrganizationName: names[2]}}}
+		close(c)
+	}()
+	saver := &SqlSaver{DbPath: testFolder, DbName: name, DBDriver: "sqlite3"}
+	if err := ListenAndSave(FilingToSaveable(c), saver); err != nil || saver.Summary().Rejected != 0 {
+		t.Fatalf("Got %v (%v), expected the filing to be saved", saver.Summary(), err)
+	}
+	db, err := sql.Open("sqlite3", testFolder+name+".sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, name := range names {
+		var n int
+		if err = db.QueryRow("SELECT COUNT(*) FROM agents WHERE organisation_name = ?", name).Scan(&n); err != nil || n != 1 {
+			t.Errorf("Got %d agents named %s (%v), expected 1", n, name, err)
+		}
+	}
+}
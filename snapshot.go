@@ -0,0 +1,436 @@
+package go_nets
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+//Binary snapshots --
+//A snapshot holds the whole network in one file, to be loaded much faster than from the database:
+//the nodes, the edges (pointing to their nodes by index) and the adjacency lists of the nodes (as edge indexes).
+//
+//Layout: the magic "GONETS", the version (uint16) and the flags (one byte, SnapshotGzip), then the body, gzipped
+//or not. The body holds the name of the network, whether it is symmetrical, the nodes, the edges and the adjacency.
+//Numbers are varints, floats are IEEE 754, strings and times (RFC 3339, empty when zero) are prefixed by their length.
+//The data of the nodes and edges is saved as JSON when its type is registered (see RegisterData),
+//as attributes otherwise.
+//
+//The version is increased with any change of the layout: a reader loads the versions up to its own,
+//and refuses the newer ones.
+
+const (
+	snapshotMagic   = "GONETS"
+	SnapshotVersion = 1
+)
+
+//Snapshot flags
+const (
+	SnapshotGzip byte = 1 << iota
+)
+
+//Tags of the data of the nodes and edges in a snapshot
+const (
+	noData byte = iota
+	registeredData
+	attributesData
+)
+
+var ErrNotSnapshot = errors.New("SNAPSHOT ERROR: not a network snapshot")
+
+//Bounds of the lengths read from a snapshot, for a corrupt one not to allocate more than it holds:
+//the strings are read as they come, and the lists of nodes and edges grow from a capped allocation.
+const (
+	maxSnapshotString = 1 << 28
+	maxSnapshotLength = 1 << 40
+	snapshotPrealloc  = 1 << 16
+)
+
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(b)
+	}
+}
+
+func (sw *snapshotWriter) byte(b byte) {
+	sw.write([]byte{b})
+}
+
+func (sw *snapshotWriter) uint(v uint64) {
+	sw.write(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+}
+
+func (sw *snapshotWriter) int(v int64) {
+	sw.write(sw.buf[:binary.PutVarint(sw.buf[:], v)])
+}
+
+func (sw *snapshotWriter) float(v float32) {
+	binary.LittleEndian.PutUint32(sw.buf[:4], math.Float32bits(v))
+	sw.write(sw.buf[:4])
+}
+
+func (sw *snapshotWriter) string(s string) {
+	sw.uint(uint64(len(s)))
+	sw.write([]byte(s))
+}
+
+func (sw *snapshotWriter) data(data AttrGetter) {
+	if data == nil {
+		sw.byte(noData)
+		return
+	}
+	if name, json, err := encodeData(data); err != nil {
+		sw.err = err
+		return
+	} else if name != nil {
+		sw.byte(registeredData)
+		sw.string(name.(string))
+		sw.string(json.(string))
+		return
+	}
+	// Attributes, sorted for the snapshots to be reproducible
+	keys := data.AttributeKeys()
+	sort.Strings(keys)
+	type attribute struct {
+		key   string
+		kind  AttrKind
+		value string
+	}
+	attributes := []attribute{}
+	for _, key := range keys {
+		kind, value, err := formatAttribute(data.GetAttribute(key))
+		if err != nil {
+			continue // Not persisted in the database either
+		}
+		attributes = append(attributes, attribute{key, kind, value})
+	}
+	sw.byte(attributesData)
+	sw.uint(uint64(len(attributes)))
+	for _, a := range attributes {
+		sw.string(a.key)
+		sw.uint(uint64(a.kind))
+		sw.string(a.value)
+	}
+}
+
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (sr *snapshotReader) byte() byte {
+	if sr.err != nil {
+		return 0
+	}
+	var b byte
+	b, sr.err = sr.r.ReadByte()
+	return b
+}
+
+func (sr *snapshotReader) uint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	var v uint64
+	v, sr.err = binary.ReadUvarint(sr.r)
+	return v
+}
+
+func (sr *snapshotReader) int() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	var v int64
+	v, sr.err = binary.ReadVarint(sr.r)
+	return v
+}
+
+//length reads a length, checking it against its bound.
+func (sr *snapshotReader) length(max uint64) int {
+	l := sr.uint()
+	if sr.err == nil && l > max {
+		sr.err = fmt.Errorf("SNAPSHOT ERROR: length %d out of range (%d)", l, max)
+		return 0
+	}
+	return int(l)
+}
+
+//prealloc is the capacity allocated for a list of the given length, before reading it.
+func prealloc(l int) int {
+	if l > snapshotPrealloc {
+		return snapshotPrealloc
+	}
+	return l
+}
+
+//index reads an index, checking it against the length of what it points to.
+func (sr *snapshotReader) index(length int) int {
+	i := sr.uint()
+	if sr.err == nil && i >= uint64(length) {
+		sr.err = fmt.Errorf("SNAPSHOT ERROR: index %d out of range (%d)", i, length)
+		return 0
+	}
+	return int(i)
+}
+
+func (sr *snapshotReader) float() float32 {
+	var b [4]byte
+	if sr.err == nil {
+		_, sr.err = io.ReadFull(sr.r, b[:])
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b[:]))
+}
+
+func (sr *snapshotReader) string() string {
+	l := sr.length(maxSnapshotString)
+	if sr.err != nil {
+		return ""
+	}
+	var b bytes.Buffer
+	if _, sr.err = io.CopyN(&b, sr.r, int64(l)); sr.err == io.EOF {
+		sr.err = io.ErrUnexpectedEOF
+	}
+	return b.String()
+}
+
+func (sr *snapshotReader) data() AttrGetter {
+	switch tag := sr.byte(); {
+	case sr.err != nil:
+		return nil
+	case tag == noData:
+		return nil
+	case tag == registeredData:
+		name, json := sr.string(), sr.string()
+		if sr.err != nil {
+			return nil
+		}
+		data, err := decodeData(name, json)
+		if err != nil {
+			sr.err = err
+		}
+		return data
+	case tag == attributesData:
+		var a Attributes // Left nil when empty, as the Attributes of the SimpleNoders
+		// The map grows with the attributes actually read, whatever their number
+		l := sr.uint()
+		if l > 0 {
+			a = Attributes{}
+		}
+		for i := 0; uint64(i) < l && sr.err == nil; i++ {
+			key, kind, value := sr.string(), AttrKind(sr.uint()), sr.string()
+			if sr.err != nil {
+				break
+			}
+			if a[key], sr.err = parseAttribute(kind, value); sr.err != nil {
+				break
+			}
+		}
+		return a
+	default:
+		sr.err = fmt.Errorf("SNAPSHOT ERROR: unknown data tag %d", tag)
+		return nil
+	}
+}
+
+//WriteSnapshot writes the snapshot of the network, gzipped or not.
+func (n *Network) WriteSnapshot(w io.Writer, flags byte) (err error) {
+	header := append([]byte(snapshotMagic), 0, 0, flags)
+	binary.BigEndian.PutUint16(header[len(snapshotMagic):], SnapshotVersion)
+	if _, err = w.Write(header); err != nil {
+		return err
+	}
+	if flags&SnapshotGzip != 0 {
+		gz := gzip.NewWriter(w)
+		defer func() {
+			if errClose := gz.Close(); err == nil {
+				err = errClose
+			}
+		}()
+		w = gz
+	}
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	// Index the nodes and edges, sorted by name for the snapshots to be reproducible
+	nodes := n.sortedNodes()
+	nodeIndex := make(map[*Node]int, len(nodes))
+	for i, node := range nodes {
+		nodeIndex[node] = i
+	}
+	edges := n.sortedEdges()
+	edgeIndex := make(map[*Edge]int, len(edges))
+	for i, edge := range edges {
+		edgeIndex[edge] = i
+	}
+	// The network
+	sw.string(n.Name)
+	if n.Symmetrical {
+		sw.byte(1)
+	} else {
+		sw.byte(0)
+	}
+	// The nodes
+	sw.uint(uint64(len(nodes)))
+	for _, node := range nodes {
+		sw.string(node.Name)
+		sw.int(int64(node.Kind))
+		sw.data(node.NodeData)
+	}
+	// The edges
+	sw.uint(uint64(len(edges)))
+	for _, edge := range edges {
+		sw.string(edge.Name)
+		sw.int(int64(edge.Kind))
+		sw.uint(uint64(nodeIndex[edge.Src]))
+		sw.uint(uint64(nodeIndex[edge.Dst]))
+		sw.float(edge.Weight)
+		sw.string(formatTime(edge.ValidFrom))
+		sw.string(formatTime(edge.ValidTo))
+		sw.data(edge.LinkData)
+	}
+	// The adjacency, keeping the order of the lists
+	for _, node := range nodes {
+		for _, ens := range [][]*EdgeToNode{node.Edges, node.InEdges} {
+			sw.uint(uint64(len(ens)))
+			for _, en := range ens {
+				sw.uint(uint64(edgeIndex[en.Edge]))
+			}
+		}
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+//ReadSnapshot loads a snapshot into the network, which must be empty. The name of the network is kept,
+//but not its symmetry: it is the one of the saved network.
+func (n *Network) ReadSnapshot(r io.Reader) error {
+	if n.Nnodes != 0 || n.Nedges != 0 {
+		return fmt.Errorf("SNAPSHOT ERROR: network %q is not empty", n.Name)
+	}
+	header := make([]byte, len(snapshotMagic)+3)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrNotSnapshot
+	}
+	if version := binary.BigEndian.Uint16(header[len(snapshotMagic):]); version > SnapshotVersion {
+		return fmt.Errorf("SNAPSHOT ERROR: version %d is newer than the supported one (%d)", version, SnapshotVersion)
+	}
+	if flags := header[len(header)-1]; flags&SnapshotGzip != 0 {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	sr := &snapshotReader{r: bufio.NewReader(r)}
+	// The network
+	sr.string() // Name of the saved network
+	symmetrical := sr.byte() == 1
+	// The nodes
+	nNodes := sr.length(maxSnapshotLength)
+	nodes := make([]*Node, 0, prealloc(nNodes))
+	for i := 0; i < nNodes; i++ {
+		nodes = append(nodes, &Node{sr.string(), NodeKind(sr.int()), nil, nil, sr.data()})
+		if sr.err != nil {
+			return sr.err
+		}
+	}
+	// The edges, and both sides of their adjacency, as done by Network.connect
+	nEdges := sr.length(maxSnapshotLength)
+	edges := make([]*Edge, 0, prealloc(nEdges))
+	out, in := make([]*EdgeToNode, 0, prealloc(nEdges)), make([]*EdgeToNode, 0, prealloc(nEdges))
+	for i := 0; i < nEdges; i++ {
+		e := &Edge{Name: sr.string(), Kind: EdgeKind(sr.int())}
+		src, dst := sr.index(len(nodes)), sr.index(len(nodes))
+		e.Weight = sr.float()
+		validFrom, validTo := sr.string(), sr.string()
+		e.LinkData = sr.data()
+		if sr.err != nil {
+			return sr.err
+		}
+		e.Src, e.Dst = nodes[src], nodes[dst]
+		var err error
+		if e.ValidFrom, err = parseTime(validFrom); err != nil {
+			return err
+		}
+		if e.ValidTo, err = parseTime(validTo); err != nil {
+			return err
+		}
+		edges = append(edges, e)
+		out, in = append(out, &EdgeToNode{e, e.Dst}), append(in, &EdgeToNode{e, e.Src})
+	}
+	// The adjacency: an entry points to the other end of the edge
+	for _, node := range nodes {
+		for _, ens := range []*[]*EdgeToNode{&node.Edges, &node.InEdges} {
+			*ens = make([]*EdgeToNode, sr.length(2*uint64(len(edges)))) // An edge is at most twice in a list (loops)
+			if sr.err != nil {
+				return sr.err
+			}
+			for j := range *ens {
+				i := sr.index(len(edges))
+				if sr.err != nil {
+					return sr.err
+				}
+				(*ens)[j] = in[i]
+				if edges[i].Src == node && (ens == &node.Edges || edges[i].Dst != node) {
+					(*ens)[j] = out[i]
+				}
+			}
+		}
+	}
+	if sr.err != nil {
+		return sr.err
+	}
+	// All good, fill the network
+	n.Symmetrical = symmetrical
+	for _, node := range nodes {
+		n.Nodes[node.Name] = node
+	}
+	for _, edge := range edges {
+		n.Edges[edge.Name] = edge
+		n.indexOriginal(edge)
+	}
+	n.Nnodes, n.Nedges = len(nodes), len(edges)
+	return nil
+}
+
+//SaveSnapshot writes the gzipped snapshot of the network to the file.
+func (n *Network) SaveSnapshot(fp string) error {
+	fmt.Printf("Trying to save a snapshot of network %q into file %q\n", n.Name, fp)
+	fi, err := os.Create(fp + TempSuffix)
+	if err != nil {
+		return err
+	}
+	if err = n.WriteSnapshot(fi, SnapshotGzip); err != nil {
+		fi.Close()
+		return err
+	}
+	if err = fi.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fp+TempSuffix, fp)
+}
+
+//LoadSnapshot loads the snapshot saved in the file into the (empty) network.
+func (n *Network) LoadSnapshot(fp string) error {
+	fmt.Printf("Trying to load a snapshot into network %q from file %q\n", n.Name, fp)
+	fi, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	return n.ReadSnapshot(fi)
+}
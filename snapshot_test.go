@@ -0,0 +1,109 @@
+package go_nets
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	fmt.Println("### TESTING the binary snapshots")
+	network := newTestNetwork("TestSnapshot", [][3]string{{"a", "b", "ER"}, {"b", "c", "EE"}, {"c", "a", "RR"}, {"a", "a", "ER"}})
+	network.Nodes["a"].SetAttribute("flagged", true)
+	network.Edges["b_c"].ValidFrom = time.Date(2014, 2, 15, 0, 0, 0, 0, time.UTC)
+	f := newTestFiling(137363375543, []string{"EMPLOYMENT DEVELOPMENT DEPARTMENT"}, []string{"john.doe", "jane.doe"})
+	network.AddDispatcher(&f)
+	for _, flags := range []byte{0, SnapshotGzip} {
+		var b bytes.Buffer
+		if err := network.WriteSnapshot(&b, flags); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Printf("Snapshot of %d bytes with flags %d\n", b.Len(), flags)
+		network2 := NewNetwork("TestSnapshot2", ioutil.Discard, testFolder)
+		if err := network2.ReadSnapshot(&b); err != nil {
+			t.Fatal(err)
+		}
+		if network2.Nnodes != network.Nnodes || network2.Nedges != network.Nedges {
+			t.Fatalf("Got %d nodes and %d edges, expected %d and %d", network2.Nnodes, network2.Nedges, network.Nnodes, network.Nedges)
+		}
+		for name, node := range network.Nodes {
+			node2 := network2.Nodes[name]
+			if !reflect.DeepEqual(node.NodeData, node2.NodeData) || node.OutDegree() != node2.OutDegree() || node.InDegree() != node2.InDegree() {
+				t.Errorf("Node %q changed from %v to %v", name, node, node2)
+			}
+			for i, en := range node.Edges {
+				if en2 := node2.Edges[i]; en2.Name != en.Name || en2.ToNode.Name != en.ToNode.Name {
+					t.Errorf("Edge %d of node %q changed from %s to %s", i, name, en.Name, en2.Name)
+				}
+			}
+		}
+		for name, edge := range network.Edges {
+			edge2 := network2.Edges[name]
+			if edge2.Src != network2.Nodes[edge.Src.Name] || edge2.Weight != edge.Weight || !edge2.ValidFrom.Equal(edge.ValidFrom) {
+				t.Errorf("Edge %q changed from %v to %v", name, edge, edge2)
+			}
+		}
+		if fd, ok := network2.Edges[network.SearchEdgesByAttribute("file_number", 137363375543)[0].Name].LinkData.(FilingData); !ok || fd.FileNumber != 137363375543 {
+			t.Errorf("Got the filing data %v", fd)
+		}
+	}
+	// Through a file
+	if err := network.SaveSnapshot(testFolder + "TestSnapshot.gonets"); err != nil {
+		t.Fatal(err)
+	}
+	network2 := NewNetwork("TestSnapshot2", ioutil.Discard, testFolder)
+	if err := network2.LoadSnapshot(testFolder + "TestSnapshot.gonets"); err != nil || network2.Nedges != network.Nedges {
+		t.Errorf("Got %d edges (%v), expected %d", network2.Nedges, err, network.Nedges)
+	}
+	if err := network2.LoadSnapshot(testFolder + "TestSnapshot.gonets"); err == nil {
+		t.Error("Loading into a non empty network should fail")
+	}
+	// Newer versions are refused
+	var b bytes.Buffer
+	network.WriteSnapshot(&b, 0)
+	b.Bytes()[len(snapshotMagic)+1]++
+	network3 := NewNetwork("TestSnapshot3", ioutil.Discard, testFolder)
+	if err := network3.ReadSnapshot(&b); err == nil {
+		t.Error("Reading a newer version should fail")
+	} else {
+		fmt.Println(err)
+	}
+	if err := network3.ReadSnapshot(bytes.NewBufferString("SQLite format 3")); err != ErrNotSnapshot {
+		t.Errorf("Got %v, expected ErrNotSnapshot", err)
+	}
+	// Truncated snapshots fail without loading anything
+	b.Reset()
+	network.WriteSnapshot(&b, 0)
+	snapshot := b.Bytes()
+	for l := len(snapshotMagic) + 3; l < len(snapshot); l++ {
+		network4 := NewNetwork("TestSnapshot4", ioutil.Discard, testFolder)
+		if err := network4.ReadSnapshot(bytes.NewReader(snapshot[:l])); err == nil || network4.Nnodes != 0 {
+			t.Errorf("Reading the first %d bytes of %d: got %d nodes (%v), expected an error", l, len(snapshot), network4.Nnodes, err)
+		}
+	}
+	// Corrupt lengths and indexes fail without allocating them
+	header := snapshot[:len(snapshotMagic)+3]
+	for name, body := range map[string][]byte{
+		"long name":      {0xff, 0xff, 0xff, 0xff, 0x0f},
+		"many nodes":     {0, 1, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		"edge to no one": {0, 1, 0, 1, 1, 'e', 0, 0, 0},
+		"long list":      {0, 1, 1, 1, 'a', 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0x0f},
+	} {
+		network4 := NewNetwork("TestSnapshot4", ioutil.Discard, testFolder)
+		if err := network4.ReadSnapshot(bytes.NewReader(append(append([]byte{}, header...), body...))); err == nil {
+			t.Errorf("Reading a snapshot with a %s should fail", name)
+		} else {
+			fmt.Println(name+":", err)
+		}
+	}
+	// Corrupt bytes fail or load, but never panic
+	for i := len(header); i < len(snapshot); i++ {
+		corrupt := append([]byte{}, snapshot...)
+		corrupt[i] ^= 0xff
+		network4 := NewNetwork("TestSnapshot4", ioutil.Discard, testFolder)
+		network4.ReadSnapshot(bytes.NewReader(corrupt))
+	}
+}
@@ -0,0 +1,141 @@
+//Time dimension of the network: validity of the edges and snapshots at a given date.
+package go_nets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//Dates of the filings --
+
+const FilingDateLayout = "20060102 1504"
+
+//Layouts accepted for the dates of the filings, the lapse dates have no time.
+var filingDateLayouts = []string{FilingDateLayout, "20060102"}
+
+func ParseFilingDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range filingDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("PARSE_DATE ERROR: %q is not a filing date", s)
+}
+
+func (f *Filing) Date() (time.Time, error) {
+	return ParseFilingDate(f.FileDate)
+}
+
+func (f *Filing) OriginalDate() (time.Time, error) {
+	return ParseFilingDate(f.OriginalFileDate)
+}
+
+func (f *Filing) Lapse() (time.Time, error) {
+	return ParseFilingDate(f.LapseDate)
+}
+
+//Conversion from and to text for persistence, the zero time being the empty string.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+//Validity of the edges --
+
+//TimedEdger is implemented by the Edgers that know when the relationship they describe is valid.
+//A zero time leaves the validity open on that side.
+type TimedEdger interface {
+	Edger
+	GetValidity() (from, to time.Time)
+}
+
+func validityOf(edger Edger) (time.Time, time.Time) {
+	if te, ok := edger.(TimedEdger); ok {
+		return te.GetValidity()
+	}
+	return time.Time{}, time.Time{}
+}
+
+//widenValidity extends the validity of the edge so that it covers [from, to) as well.
+func (e *Edge) widenValidity(from, to time.Time) {
+	if from.IsZero() || (!e.ValidFrom.IsZero() && from.Before(e.ValidFrom)) {
+		e.ValidFrom = from
+	}
+	if to.IsZero() || (!e.ValidTo.IsZero() && to.After(e.ValidTo)) {
+		e.ValidTo = to
+	}
+}
+
+//ActiveAt tells if the edge is valid at time t.
+func (e *Edge) ActiveAt(t time.Time) bool {
+	return (e.ValidFrom.IsZero() || !t.Before(e.ValidFrom)) &&
+		(e.ValidTo.IsZero() || t.Before(e.ValidTo))
+}
+
+//ActiveDuring tells if the edge is valid at some point of the window [from, to).
+func (e *Edge) ActiveDuring(from, to time.Time) bool {
+	return (e.ValidFrom.IsZero() || e.ValidFrom.Before(to)) &&
+		(e.ValidTo.IsZero() || e.ValidTo.After(from))
+}
+
+//Snapshots --
+
+//SubNetwork returns a new network made of the edges for which keep returns true, and of their nodes.
+//The nodes and edges are copied, but their data is shared with the original network.
+func (n *Network) SubNetwork(name string, keep func(*Edge) bool) Network {
+	sub := *n // Same parameters
+	sub.Name = name
+	sub.Edges, sub.Nedges, sub.originals = make(map[string]*Edge), 0, nil
+	sub.Nodes, sub.Nnodes = make(map[string]*Node), 0
+	sub.PersistingFile = name + ".sqlite"
+	for _, edge := range n.Edges {
+		if keep(edge) {
+			sub.connect(&Edge{
+				edge.Name,
+				edge.Kind,
+				sub.copyNode(edge.Src),
+				sub.copyNode(edge.Dst),
+				edge.Weight,
+				edge.ValidFrom,
+				edge.ValidTo,
+				edge.LinkData,
+			})
+		}
+	}
+	return sub
+}
+
+func (n *Network) copyNode(node *Node) *Node {
+	if copied, ok := n.Nodes[node.Name]; ok {
+		return copied
+	}
+	copied := &Node{node.Name, node.Kind, []*EdgeToNode{}, []*EdgeToNode{}, node.NodeData}
+	n.Nodes[node.Name] = copied
+	n.Nnodes++
+	return copied
+}
+
+//AsOf returns the network as it was at time t: the edges valid at that time, and the nodes they connect.
+func (n *Network) AsOf(t time.Time) Network {
+	return n.SubNetwork(n.Name+"_asof_"+t.Format("20060102"), func(e *Edge) bool {
+		return e.ActiveAt(t)
+	})
+}
+
+//Window returns the network of the edges valid at some point between from (included) and to (excluded).
+func (n *Network) Window(from, to time.Time) Network {
+	return n.SubNetwork(n.Name+"_"+from.Format("20060102")+"_"+to.Format("20060102"), func(e *Edge) bool {
+		return e.ActiveDuring(from, to)
+	})
+}
@@ -0,0 +1,80 @@
+package go_nets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestParseFilingDate(t *testing.T) {
+	d, err := ParseFilingDate("20130522 1700")
+	if err != nil || !d.Equal(time.Date(2013, 5, 22, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("Got %v (%v) for '20130522 1700'", d, err)
+	}
+	if d, err = ParseFilingDate(" 20230522"); err != nil || d.Day() != 22 {
+		t.Errorf("Got %v (%v) for a lapse date", d, err)
+	}
+	if _, err = ParseFilingDate("22/05/2013"); err == nil {
+		t.Error("A malformed date should not be parsed")
+	}
+}
+
+func TestTemporal(t *testing.T) {
+	fmt.Println("### TESTING the time-sliced snapshots")
+	network := NewNetwork("TestTemporal", ioutil.Discard, testFolder)
+	dated := func(fileNumber int, date string, securers []string, debtors []string) Filing {
+		f := newTestFiling(fileNumber, securers, debtors)
+		f.FileDate, f.OriginalFileDate = date, date
+		return f
+	}
+	filings := []Filing{
+		dated(1, "20120110 0900", []string{"BANK"}, []string{"john.doe"}),
+		dated(2, "20120815 0900", []string{"BANK"}, []string{"jane.doe"}),
+		dated(3, "20130301 0900", []string{"OTHER BANK"}, []string{"jane.doe"}),
+	}
+	for i := range filings {
+		network.AddDispatcher(&filings[i])
+	}
+	edge := network.Edges["bank_2_jane.doe94280"]
+	if edge == nil {
+		t.Fatalf("Missing edge, got %v", network.Edges)
+	}
+	if d, _ := GetTime(edge.LinkData, "file_date"); !d.Equal(edge.ValidFrom) || d.Month() != time.August {
+		t.Errorf("The edge should be valid from its filing date, got %v (filed %v)", edge.ValidFrom, d)
+	}
+	// Quarter ends
+	expected := map[string][2]int{ // edges, nodes
+		"20111231": {0, 0},
+		"20120331": {1, 2},
+		"20120930": {2, 3},
+		"20130331": {3, 4},
+	}
+	for date, e := range expected {
+		d, _ := time.Parse("20060102", date)
+		snapshot := network.AsOf(d.Add(24 * time.Hour))
+		snapshot.Summary(nil)
+		if snapshot.Nedges != e[0] || snapshot.Nnodes != e[1] {
+			t.Errorf("As of %s: got %d edges and %d nodes, expected %d and %d", date, snapshot.Nedges, snapshot.Nnodes, e[0], e[1])
+		}
+		checkAdjacency(t, &snapshot)
+	}
+	// Closed edges and windows
+	edge.ValidTo = time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC)
+	if snapshot := network.AsOf(time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)); snapshot.Nedges != 2 {
+		t.Errorf("The closed edge should not be in the snapshot, got %d edges", snapshot.Nedges)
+	}
+	window := network.Window(time.Date(2012, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2012, 10, 1, 0, 0, 0, 0, time.UTC))
+	if window.Nedges != 2 || window.Edges["bank_2_jane.doe94280"] == nil {
+		t.Errorf("Got %d edges in the window, expected 2", window.Nedges)
+	}
+	// Persistence of the validity
+	network.SaveAs(testFolder + "TestTemporal.sqlite")
+	network2 := NewNetwork("TestTemporal2", ioutil.Discard, testFolder)
+	network2.LoadFrom(testFolder + "TestTemporal.sqlite")
+	for name, e := range network.Edges {
+		if e2 := network2.Edges[name]; !e.ValidFrom.Equal(e2.ValidFrom) || !e.ValidTo.Equal(e2.ValidTo) {
+			t.Errorf("Validity of edge %q changed from [%v, %v) to [%v, %v) when loading", name, e.ValidFrom, e.ValidTo, e2.ValidFrom, e2.ValidTo)
+		}
+	}
+}